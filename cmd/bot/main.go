@@ -11,15 +11,28 @@ import (
 	"bitbucket-slack-bot/internal/bitbucket"
 	"bitbucket-slack-bot/internal/config"
 	"bitbucket-slack-bot/internal/db"
+	"bitbucket-slack-bot/internal/scheduler"
+	"bitbucket-slack-bot/internal/secrets"
 	slackbot "bitbucket-slack-bot/internal/slack"
 	"bitbucket-slack-bot/internal/store"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
-	slacklib "github.com/slack-go/slack"
 )
 
+// outboxWorkers is the number of goroutines delivering queued Slack messages.
+const outboxWorkers = 4
+
+// digestPollInterval is how often the scheduler looks for due channel digests.
+const digestPollInterval = time.Minute
+
+// reminderPollInterval is how often the scheduler checks open PRs for due review reminders.
+const reminderPollInterval = 5 * time.Minute
+
+// purgePollInterval is how often the scheduler deletes expired bookkeeping rows.
+const purgePollInterval = time.Hour
+
 // requestLogger returns a Fiber middleware that logs full request and response details.
 func requestLogger(log *slog.Logger) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -42,7 +55,7 @@ func requestLogger(log *slog.Logger) fiber.Handler {
 		}
 		// Log selected headers that are useful for debugging webhooks.
 		for _, h := range []string{
-			"Content-Type", "X-Event-Key", "X-Hub-Signature",
+			"Content-Type", "X-Event-Key", "X-Hub-Signature", "X-Request-UUID",
 			"X-Slack-Signature", "X-Slack-Request-Timestamp",
 		} {
 			if v := c.Get(h); v != "" {
@@ -60,6 +73,22 @@ func main() {
 		Level: slog.LevelInfo,
 	}))
 
+	// Maintenance subcommands run instead of the server.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Error("migrate error", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rotate-token-key" {
+		if err := runRotateTokenKey(log, os.Args[2:]); err != nil {
+			log.Error("rotate token key error", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Error("config error", "err", err)
@@ -68,6 +97,15 @@ func main() {
 
 	log.Info("starting", "addr", cfg.ServerAddr)
 
+	keyring, err := secrets.LoadKeyring(cfg.TokenKeys, cfg.TokenKeysFile)
+	if err != nil {
+		log.Error("token key error", "err", err)
+		os.Exit(1)
+	}
+	if keyring == nil {
+		log.Warn("no --token-keys configured, OAuth and Slack bot tokens are stored in plaintext")
+	}
+
 	// PostgreSQL connection pool.
 	pool, err := db.Connect(context.Background(), cfg.DatabaseURL)
 	if err != nil {
@@ -78,14 +116,20 @@ func main() {
 	log.Info("db connected", "max_conns", pool.Config().MaxConns)
 
 	// DB-backed repo subscription + OAuth token store.
-	repoStore := store.NewRepoStore(pool)
+	repoStore := store.NewRepoStore(pool, keyring)
 	if err := repoStore.Migrate(context.Background()); err != nil {
 		log.Error("db migrate error", "err", err)
 		os.Exit(1)
 	}
 
-	// Slack client.
-	slackClient := slacklib.New(cfg.SlackBotToken)
+	// Slack clients, resolved per team (installed bot token or --slack-bot-token fallback).
+	slackClients := slackbot.NewClients(repoStore, cfg.SlackBotToken)
+
+	// Durable outbound Slack delivery, shared by every webhook-driven notification.
+	outbox := slackbot.NewOutbox(repoStore, slackClients, log)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go outbox.Run(workerCtx, outboxWorkers)
 
 	// Bitbucket OAuth handler.
 	oauthHandler := bitbucket.NewOAuthHandler(
@@ -93,17 +137,33 @@ func main() {
 		cfg.BitbucketClientSecret,
 		cfg.PublicURL,
 		repoStore,
-		slackClient,
+		slackClients,
 		log,
 	)
 
-	// refreshFn wraps OAuthHandler.RefreshTokenBg for use in the Slack handler.
-	refreshFn := func(rec *store.TokenRecord) (*store.TokenRecord, error) {
-		return oauthHandler.RefreshTokenBg(context.Background(), rec)
-	}
+	// Keep workspace tokens fresh and flag revoked connections.
+	go bitbucket.NewTokenRefresher(oauthHandler, repoStore, outbox, log).Run(workerCtx)
 
 	// Slack webhook handler.
-	slackHandler := slackbot.NewHandler(slackClient, repoStore, oauthHandler.AuthURL, oauthHandler.AuthLoginURL, cfg.PublicURL, log)
+	slackHandler := slackbot.NewHandler(slackClients, repoStore, oauthHandler.AuthURL, oauthHandler.AuthLoginURL, oauthHandler.ProviderFor, oauthHandler.UserProviderFor, cfg.PublicURL, log)
+
+	// Bitbucket webhook handler.
+	webhookHandler := bitbucket.NewWebhookHandler(outbox, repoStore, oauthHandler.ProviderFor, slackHandler.RefreshHomes, log)
+
+	// Periodic jobs: scheduled channel digests, review reminders and cleanup of
+	// failed deliveries and expired webhook delivery records.
+	sched := scheduler.New(log)
+	sched.Every(digestPollInterval, "digests", slackHandler.RunDueDigests)
+	sched.Every(reminderPollInterval, "reminders", slackbot.NewReminders(outbox, repoStore, log).Run)
+	sched.Every(purgePollInterval, "purge failed outbox", outbox.PurgeFailed)
+	sched.Every(purgePollInterval, "purge webhook deliveries", webhookHandler.PurgeDeliveries)
+	go sched.Run(workerCtx)
+
+	// Slack "Add to Slack" install flow (optional).
+	var installHandler *slackbot.InstallHandler
+	if cfg.SlackInstallEnabled() {
+		installHandler = slackbot.NewInstallHandler(cfg.SlackClientID, cfg.SlackClientSecret, cfg.PublicURL, repoStore, slackClients, log)
+	}
 
 	// Fiber app.
 	app := fiber.New(fiber.Config{
@@ -120,11 +180,8 @@ func main() {
 		return c.JSON(fiber.Map{"status": "ok"})
 	})
 
-	slackbot.RegisterRoutes(app, slackHandler, cfg.SlackSignSecret, refreshFn)
-	bitbucket.RegisterRoutes(app,
-		bitbucket.NewWebhookHandler(slackClient, repoStore, log),
-		oauthHandler,
-	)
+	slackbot.RegisterRoutes(app, slackHandler, installHandler, cfg.SlackSignSecret)
+	bitbucket.RegisterRoutes(app, webhookHandler, oauthHandler)
 
 	// Graceful shutdown.
 	quit := make(chan os.Signal, 1)
@@ -139,6 +196,7 @@ func main() {
 
 	<-quit
 	log.Info("shutting down")
+	stopWorkers()
 	if err := app.Shutdown(); err != nil {
 		log.Error("shutdown error", "err", err)
 	}
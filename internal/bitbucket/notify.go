@@ -0,0 +1,45 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+
+	"bitbucket-slack-bot/internal/store"
+)
+
+// notifyUser queues a personal DM to each Slack user linked to recipient in a team
+// subscribed to the PR's repository, if they turned kind on with /notify. text renders
+// the message given the actor as mentioned in the recipient's team. Nobody is notified
+// about their own actions.
+func (h *WebhookHandler) notifyUser(ctx context.Context, kind, recipient, actor string, rec store.PRCommitRecord, text func(actorLabel string) string) {
+	if recipient == "" || recipient == actor {
+		return
+	}
+	teams, err := h.subscribedTeams(ctx, rec.RepoSlug)
+	if err != nil {
+		h.log.Error("look up channels for repo", "repo", rec.RepoSlug, "err", err)
+		return
+	}
+	if len(teams) == 0 {
+		return
+	}
+	recipients, err := h.repoStore.NotificationRecipients(ctx, teams, recipient, kind)
+	if err != nil {
+		h.log.Error("look up notification recipients", "bitbucket", recipient, "kind", kind, "err", err)
+		return
+	}
+	for _, r := range recipients {
+		var actorLabel string
+		if actor != "" {
+			actorLabel = h.resolveUser(ctx, r.TeamID, actor)
+		}
+		if err := h.outbox.DM(ctx, r.TeamID, r.SlackUserID, rec.RepoSlug, rec.PRID, text(actorLabel)); err != nil {
+			h.log.Error("queue personal notification", "user", r.SlackUserID, "kind", kind, "err", err)
+		}
+	}
+}
+
+// dmPRLink renders a PR reference for personal notifications, e.g. "<url|#12 Fix login> in `acme/web`".
+func dmPRLink(rec store.PRCommitRecord) string {
+	return fmt.Sprintf("<%s|#%d %s> in `%s`", rec.URL, rec.PRID, rec.Title, rec.RepoSlug)
+}
@@ -3,6 +3,7 @@ package bitbucket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,6 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"bitbucket-slack-bot/internal/provider"
+	slackbot "bitbucket-slack-bot/internal/slack"
 	"bitbucket-slack-bot/internal/store"
 
 	"github.com/gofiber/fiber/v2"
@@ -25,66 +28,90 @@ type OAuthHandler struct {
 	clientSecret string
 	publicURL    string
 	repoStore    *store.RepoStore
-	slack        *slacklib.Client
+	clients      *slackbot.Clients
 	log          *slog.Logger
 }
 
-func NewOAuthHandler(clientID, clientSecret, publicURL string, repoStore *store.RepoStore, slack *slacklib.Client, log *slog.Logger) *OAuthHandler {
+func NewOAuthHandler(clientID, clientSecret, publicURL string, repoStore *store.RepoStore, clients *slackbot.Clients, log *slog.Logger) *OAuthHandler {
 	return &OAuthHandler{
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		publicURL:    publicURL,
 		repoStore:    repoStore,
-		slack:        slack,
+		clients:      clients,
 		log:          log,
 	}
 }
 
+// oauthStateTTL bounds how long an authorization link stays valid.
+const oauthStateTTL = 10 * time.Minute
+
 // AuthURL returns the Bitbucket OAuth2 authorization URL for workspace connect.
-// state encodes "connect:teamID:channelID:userID:workspace".
-func (h *OAuthHandler) AuthURL(teamID, channelID, userID, workspace string) string {
-	state := "connect:" + teamID + ":" + channelID + ":" + userID + ":" + workspace
-	return fmt.Sprintf(
-		"https://bitbucket.org/site/oauth2/authorize?client_id=%s&response_type=code&state=%s",
-		h.clientID, url.QueryEscape(state),
-	)
+// The team, channel, user and workspace are kept server-side behind a single-use state nonce.
+func (h *OAuthHandler) AuthURL(teamID, channelID, userID, workspace string) (string, error) {
+	return h.authorizeURL(store.OAuthState{
+		Kind:      "connect",
+		TeamID:    teamID,
+		ChannelID: channelID,
+		UserID:    userID,
+		Workspace: workspace,
+	})
 }
 
 // AuthLoginURL returns the Bitbucket OAuth2 authorization URL for user identity linking.
-// state encodes "login:slackUserID:channelID".
-func (h *OAuthHandler) AuthLoginURL(slackUserID, channelID string) string {
-	state := "login:" + slackUserID + ":" + channelID
+// The Slack team, user and channel are kept server-side behind a single-use state nonce.
+func (h *OAuthHandler) AuthLoginURL(teamID, slackUserID, channelID string) (string, error) {
+	return h.authorizeURL(store.OAuthState{
+		Kind:      "login",
+		TeamID:    teamID,
+		ChannelID: channelID,
+		UserID:    slackUserID,
+	})
+}
+
+func (h *OAuthHandler) authorizeURL(st store.OAuthState) (string, error) {
+	nonce, err := h.repoStore.CreateOAuthState(context.Background(), st, oauthStateTTL)
+	if err != nil {
+		return "", fmt.Errorf("create oauth state: %w", err)
+	}
 	return fmt.Sprintf(
 		"https://bitbucket.org/site/oauth2/authorize?client_id=%s&response_type=code&state=%s",
-		h.clientID, url.QueryEscape(state),
-	)
+		h.clientID, url.QueryEscape(nonce),
+	), nil
 }
 
 // HandleCallback processes the OAuth2 redirect from Bitbucket.
-// Dispatches to handleConnect or handleLogin based on the state prefix.
+// The state nonce is consumed before anything else, so replayed, expired or
+// forged states are rejected. Dispatches to handleConnect or handleLogin based on its kind.
 func (h *OAuthHandler) HandleCallback(c *fiber.Ctx) error {
 	code := c.Query("code")
-	state := c.Query("state")
+	nonce := c.Query("state")
 
-	if code == "" || state == "" {
+	if code == "" || nonce == "" {
 		return c.Status(fiber.StatusBadRequest).SendString("missing code or state")
 	}
 
-	if strings.HasPrefix(state, "login:") {
-		return h.handleLogin(c, code, strings.TrimPrefix(state, "login:"))
+	st, err := h.repoStore.ConsumeOAuthState(c.Context(), nonce)
+	if err != nil {
+		h.log.Error("consume oauth state", "err", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("internal error")
 	}
-	if strings.HasPrefix(state, "connect:") {
-		return h.handleConnect(c, code, strings.TrimPrefix(state, "connect:"))
+	if st == nil {
+		h.log.Warn("oauth callback with unknown or expired state")
+		return c.Status(fiber.StatusBadRequest).SendString("invalid or expired state — run the command again in Slack")
+	}
+
+	switch st.Kind {
+	case "login":
+		return h.handleLogin(c, code, st)
+	case "connect":
+		return h.handleConnect(c, code, st)
 	}
 	return c.Status(fiber.StatusBadRequest).SendString("invalid state")
 }
 
-func (h *OAuthHandler) handleConnect(c *fiber.Ctx, code, stateBody string) error {
-	parts := strings.SplitN(stateBody, ":", 4)
-	if len(parts) != 4 {
-		return c.Status(fiber.StatusBadRequest).SendString("invalid state")
-	}
-	teamID, channelID, userID, workspace := parts[0], parts[1], parts[2], parts[3]
+func (h *OAuthHandler) handleConnect(c *fiber.Ctx, code string, st *store.OAuthState) error {
+	teamID, channelID, userID, workspace := st.TeamID, st.ChannelID, st.UserID, st.Workspace
 
 	token, err := h.exchangeCode(code)
 	if err != nil {
@@ -93,25 +120,23 @@ func (h *OAuthHandler) handleConnect(c *fiber.Ctx, code, stateBody string) error
 	}
 
 	expiresAt := time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
-	if err := h.repoStore.SaveToken(c.Context(), teamID, workspace, token.AccessToken, token.RefreshToken, expiresAt); err != nil {
+	if err := h.repoStore.SaveToken(c.Context(), teamID, workspace, token.AccessToken, token.RefreshToken, expiresAt, userID); err != nil {
 		h.log.Error("save token failed", "team", teamID, "err", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to save token")
 	}
 
 	h.log.Info("bitbucket workspace connected", "team", teamID, "workspace", workspace)
-	_, _ = h.slack.PostEphemeral(channelID, userID, slacklib.MsgOptionText(
-		fmt.Sprintf(":white_check_mark: Bitbucket workspace `%s` connected!", workspace),
-		false,
-	))
+	if client, err := h.clients.For(c.Context(), teamID); err == nil {
+		_, _ = client.PostEphemeral(channelID, userID, slacklib.MsgOptionText(
+			fmt.Sprintf(":white_check_mark: Bitbucket workspace `%s` connected!", workspace),
+			false,
+		))
+	}
 	return c.SendString("Bitbucket connected! You can close this tab and return to Slack.")
 }
 
-func (h *OAuthHandler) handleLogin(c *fiber.Ctx, code, stateBody string) error {
-	parts := strings.SplitN(stateBody, ":", 2)
-	if len(parts) != 2 {
-		return c.Status(fiber.StatusBadRequest).SendString("invalid state")
-	}
-	slackUserID, channelID := parts[0], parts[1]
+func (h *OAuthHandler) handleLogin(c *fiber.Ctx, code string, st *store.OAuthState) error {
+	slackUserID, channelID := st.UserID, st.ChannelID
 
 	token, err := h.exchangeCode(code)
 	if err != nil {
@@ -125,16 +150,25 @@ func (h *OAuthHandler) handleLogin(c *fiber.Ctx, code, stateBody string) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to fetch Bitbucket user")
 	}
 
-	if err := h.repoStore.SaveUserMapping(c.Context(), slackUserID, bbUser.DisplayName); err != nil {
+	if err := h.repoStore.SaveUserMapping(c.Context(), st.TeamID, slackUserID, bbUser.DisplayName, bbUser.Nickname, bbUser.UUID); err != nil {
 		h.log.Error("save user mapping failed", "slack_user", slackUserID, "err", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to save user mapping")
 	}
 
+	// Keep the user's own token so card buttons can act on PRs as them.
+	expiresAt := time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	if err := h.repoStore.SaveUserToken(c.Context(), st.TeamID, slackUserID, token.AccessToken, token.RefreshToken, expiresAt); err != nil {
+		h.log.Error("save user token failed", "slack_user", slackUserID, "err", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save token")
+	}
+
 	h.log.Info("user linked", "slack_user", slackUserID, "bitbucket_user", bbUser.DisplayName)
-	_, _, _ = h.slack.PostMessage(channelID, slacklib.MsgOptionText(
-		fmt.Sprintf(":white_check_mark: <@%s> linked to Bitbucket account *%s*. PR notifications will now mention you directly.", slackUserID, bbUser.DisplayName),
-		false,
-	))
+	if client, err := h.clients.For(c.Context(), st.TeamID); err == nil {
+		_, _, _ = client.PostMessage(channelID, slacklib.MsgOptionText(
+			fmt.Sprintf(":white_check_mark: <@%s> linked to Bitbucket account *%s*. PR notifications will now mention you directly.", slackUserID, bbUser.DisplayName),
+			false,
+		))
+	}
 	return c.SendString("Bitbucket account linked! You can close this tab and return to Slack.")
 }
 
@@ -165,6 +199,8 @@ func (h *OAuthHandler) fetchBitbucketUser(accessToken string) (*bbUser, error) {
 
 type bbUser struct {
 	DisplayName string `json:"display_name"`
+	Nickname    string `json:"nickname"`
+	UUID        string `json:"uuid"`
 	AccountID   string `json:"account_id"`
 }
 
@@ -180,7 +216,7 @@ func (h *OAuthHandler) RefreshTokenBg(ctx context.Context, rec *store.TokenRecor
 	}
 
 	expiresAt := time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
-	if err := h.repoStore.SaveToken(ctx, rec.TeamID, rec.Workspace, token.AccessToken, token.RefreshToken, expiresAt); err != nil {
+	if err := h.repoStore.SaveToken(ctx, rec.TeamID, rec.Workspace, token.AccessToken, token.RefreshToken, expiresAt, rec.ConnectedBy); err != nil {
 		return nil, err
 	}
 
@@ -190,9 +226,63 @@ func (h *OAuthHandler) RefreshTokenBg(ctx context.Context, rec *store.TokenRecor
 		AccessToken:  token.AccessToken,
 		RefreshToken: token.RefreshToken,
 		ExpiresAt:    expiresAt,
+		ConnectedBy:  rec.ConnectedBy,
 	}, nil
 }
 
+// ProviderFor returns a Bitbucket provider authenticated as teamID's connected workspace,
+// refreshing the access token first if it is about to expire. Returns nil (no error)
+// when the team has no usable connection.
+func (h *OAuthHandler) ProviderFor(ctx context.Context, teamID string) (provider.Provider, error) {
+	rec, err := h.repoStore.GetToken(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("look up credentials: %w", err)
+	}
+	if rec == nil || rec.Disconnected {
+		return nil, nil
+	}
+	if time.Until(rec.ExpiresAt) < 5*time.Minute {
+		if rec, err = h.RefreshTokenBg(ctx, rec); err != nil {
+			return nil, fmt.Errorf("token refresh failed: %w", err)
+		}
+	}
+	return provider.NewOAuth(rec.Workspace, rec.AccessToken), nil
+}
+
+// UserProviderFor returns a Bitbucket provider for workspace authenticated as the Slack
+// user's own account linked in teamID, refreshing their token first if it is about to expire. Returns
+// nil (no error) when the user has not linked their account with /login, or when
+// Bitbucket has revoked their token, which is then forgotten.
+func (h *OAuthHandler) UserProviderFor(ctx context.Context, teamID, slackUserID, workspace string) (provider.Provider, error) {
+	rec, err := h.repoStore.GetUserToken(ctx, teamID, slackUserID)
+	if err != nil {
+		return nil, fmt.Errorf("look up credentials: %w", err)
+	}
+	if rec == nil {
+		return nil, nil
+	}
+	if time.Until(rec.ExpiresAt) < 5*time.Minute {
+		token, err := h.doTokenRequest(url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {rec.RefreshToken},
+		})
+		var tokenErr *TokenRequestError
+		if errors.As(err, &tokenErr) && tokenErr.Revoked() {
+			h.log.Warn("user token revoked", "team", teamID, "slack_user", slackUserID, "err", err)
+			return nil, h.repoStore.DeleteUserToken(ctx, teamID, slackUserID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("token refresh failed: %w", err)
+		}
+		expiresAt := time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+		if err := h.repoStore.SaveUserToken(ctx, teamID, slackUserID, token.AccessToken, token.RefreshToken, expiresAt); err != nil {
+			return nil, err
+		}
+		rec.AccessToken = token.AccessToken
+	}
+	return provider.NewOAuth(workspace, rec.AccessToken), nil
+}
+
 type bbTokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
@@ -200,6 +290,22 @@ type bbTokenResponse struct {
 	TokenType    string `json:"token_type"`
 }
 
+// TokenRequestError is returned when Bitbucket's token endpoint rejects a request.
+type TokenRequestError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *TokenRequestError) Error() string {
+	return fmt.Sprintf("token request failed %d: %s", e.StatusCode, e.Body)
+}
+
+// Revoked reports whether the grant itself was rejected (e.g. a revoked refresh
+// token or a removed consumer), as opposed to a transient server error.
+func (e *TokenRequestError) Revoked() bool {
+	return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnauthorized
+}
+
 func (h *OAuthHandler) exchangeCode(code string) (*bbTokenResponse, error) {
 	return h.doTokenRequest(url.Values{
 		"grant_type": {"authorization_code"},
@@ -223,7 +329,7 @@ func (h *OAuthHandler) doTokenRequest(params url.Values) (*bbTokenResponse, erro
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("token request failed %d: %s", resp.StatusCode, body)
+		return nil, &TokenRequestError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var t bbTokenResponse
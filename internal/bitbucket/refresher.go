@@ -0,0 +1,98 @@
+package bitbucket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	slackbot "bitbucket-slack-bot/internal/slack"
+	"bitbucket-slack-bot/internal/store"
+)
+
+const (
+	// refreshInterval is how often the refresher scans for expiring tokens.
+	refreshInterval = 10 * time.Minute
+	// refreshAhead refreshes tokens this long before they expire. It must exceed
+	// refreshInterval so no token expires between two scans.
+	refreshAhead = 30 * time.Minute
+	// refreshLease is how long a claimed token stays invisible to other instances. It is
+	// shorter than refreshInterval so a failed refresh is retried on the next scan.
+	refreshLease = 5 * time.Minute
+)
+
+// TokenRefresher keeps every connected workspace's access token fresh in the
+// background. Workspaces whose refresh token is rejected are marked disconnected
+// and the user who connected them is told how to reconnect. Tokens are claimed
+// before refreshing, so every bot instance may run one.
+type TokenRefresher struct {
+	oauth     *OAuthHandler
+	repoStore *store.RepoStore
+	outbox    *slackbot.Outbox
+	log       *slog.Logger
+}
+
+func NewTokenRefresher(oauth *OAuthHandler, repoStore *store.RepoStore, outbox *slackbot.Outbox, log *slog.Logger) *TokenRefresher {
+	return &TokenRefresher{oauth: oauth, repoStore: repoStore, outbox: outbox, log: log}
+}
+
+// Run refreshes due tokens immediately and then every refreshInterval until ctx is cancelled.
+func (r *TokenRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		r.refreshDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *TokenRefresher) refreshDue(ctx context.Context) {
+	recs, err := r.repoStore.ClaimExpiringTokens(ctx, time.Now().Add(refreshAhead), refreshLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.log.Error("claim expiring tokens", "err", err)
+		}
+		return
+	}
+
+	for _, rec := range recs {
+		if _, err := r.oauth.RefreshTokenBg(ctx, &rec); err != nil {
+			var tokenErr *TokenRequestError
+			if errors.As(err, &tokenErr) && tokenErr.Revoked() {
+				r.disconnect(ctx, rec, err)
+				continue
+			}
+			// Network errors and Bitbucket outages are retried on the next scan.
+			r.log.Warn("token refresh failed, will retry", "team", rec.TeamID, "workspace", rec.Workspace, "err", err)
+			continue
+		}
+		r.log.Info("token refreshed", "team", rec.TeamID, "workspace", rec.Workspace)
+	}
+}
+
+// disconnect marks the workspace as disconnected and DMs whoever connected it.
+// The DM asks them to run /repo connect rather than linking an OAuth URL, whose
+// state would expire long before they might read it.
+func (r *TokenRefresher) disconnect(ctx context.Context, rec store.TokenRecord, cause error) {
+	r.log.Error("token refresh rejected, marking workspace disconnected", "team", rec.TeamID, "workspace", rec.Workspace, "err", cause)
+	if err := r.repoStore.MarkTokenDisconnected(ctx, rec.TeamID); err != nil {
+		r.log.Error("mark token disconnected", "team", rec.TeamID, "err", err)
+		return
+	}
+	if rec.ConnectedBy == "" {
+		return
+	}
+
+	text := fmt.Sprintf(
+		":warning: Bitbucket rejected the saved credentials for workspace `%s`, so PR features that call the Bitbucket API are paused.\nRun `/repo connect %s` to reconnect it.",
+		rec.Workspace, rec.Workspace,
+	)
+	if err := r.outbox.DM(ctx, rec.TeamID, rec.ConnectedBy, "", 0, text); err != nil {
+		r.log.Error("queue reconnect notice", "user", rec.ConnectedBy, "err", err)
+	}
+}
@@ -0,0 +1,145 @@
+package bitbucket
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"bitbucket-slack-bot/internal/codeowners"
+	"bitbucket-slack-bot/internal/provider"
+	"bitbucket-slack-bot/internal/store"
+)
+
+// gitForRepo returns a provider for the workspace that owns repoSlug, using the token of
+// any subscribed team connected to it. subs may be nil, in which case they are looked up.
+// Returns nil when no such team is connected.
+func (h *WebhookHandler) gitForRepo(ctx context.Context, repoSlug string, subs []store.Subscription) provider.Provider {
+	if subs == nil {
+		var err error
+		if subs, err = h.repoStore.ChannelsForRepo(ctx, repoSlug); err != nil {
+			h.log.Error("look up channels for repo", "repo", repoSlug, "err", err)
+			return nil
+		}
+	}
+
+	workspace, _, _ := strings.Cut(repoSlug, "/")
+	tried := make(map[string]bool)
+	for _, sub := range subs {
+		if tried[sub.TeamID] {
+			continue
+		}
+		tried[sub.TeamID] = true
+
+		git, err := h.providerFor(ctx, sub.TeamID)
+		if err != nil {
+			h.log.Warn("bitbucket provider", "team", sub.TeamID, "err", err)
+			continue
+		}
+		if git != nil && strings.EqualFold(git.Workspace(), workspace) {
+			return git
+		}
+	}
+	return nil
+}
+
+// changedPaths fetches the files a PR touches. Returns nil when they cannot be fetched,
+// which disables path filtering for the PR rather than dropping its notifications.
+func (h *WebhookHandler) changedPaths(ctx context.Context, git provider.Provider, repoSlug string, prID int) []string {
+	_, name, _ := strings.Cut(repoSlug, "/")
+	paths, err := git.ListChangedFiles(ctx, name, prID)
+	if err != nil {
+		h.log.Error("list changed files", "repo", repoSlug, "pr", prID, "err", err)
+		return nil
+	}
+	if paths == nil {
+		paths = []string{}
+	}
+	return paths
+}
+
+// codeOwners returns the owners of the files a PR changes, other than its author, per
+// the CODEOWNERS file on its destination branch. Returns nil when there is no
+// CODEOWNERS file or no owned file changed.
+func (h *WebhookHandler) codeOwners(ctx context.Context, git provider.Provider, rec store.PRCommitRecord, author bbAccount) []string {
+	if len(rec.ChangedPaths) == 0 {
+		return nil
+	}
+	file := h.loadCodeOwners(ctx, git, rec.RepoSlug, rec.DestBranch)
+	if file == nil {
+		return nil
+	}
+
+	var owners []string
+	for _, o := range file.Owners(rec.ChangedPaths) {
+		if !author.isOwner(o) {
+			owners = append(owners, o)
+		}
+	}
+	return owners
+}
+
+// resolveOwners resolves CODEOWNERS owners to Slack mentions of teamID's users joined
+// with ", ". "@" handles are matched against the linked accounts' nicknames and UUIDs,
+// other owners against their display names.
+func (h *WebhookHandler) resolveOwners(ctx context.Context, teamID string, owners []string) string {
+	labels := make([]string, len(owners))
+	for i, o := range owners {
+		handle, ok := strings.CutPrefix(o, "@")
+		if !ok {
+			labels[i] = h.resolveUser(ctx, teamID, o)
+			continue
+		}
+		id, err := h.repoStore.GetSlackUserByBitbucketHandle(ctx, teamID, handle)
+		if err != nil {
+			h.log.Warn("resolve code owner", "team", teamID, "owner", o, "err", err)
+		}
+		if id != "" {
+			labels[i] = "<@" + id + ">"
+		} else {
+			labels[i] = "*" + o + "*"
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// loadCodeOwners reads the first CODEOWNERS file found at ref, or returns nil.
+func (h *WebhookHandler) loadCodeOwners(ctx context.Context, git provider.Provider, repoSlug, ref string) *codeowners.File {
+	_, name, _ := strings.Cut(repoSlug, "/")
+	for _, path := range codeowners.Locations {
+		data, err := git.GetFileContent(ctx, name, ref, path)
+		if errors.Is(err, provider.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			h.log.Error("read CODEOWNERS", "repo", repoSlug, "path", path, "err", err)
+			return nil
+		}
+		return codeowners.Parse(data)
+	}
+	return nil
+}
+
+// recordEvent describes a notification about the PR in rec for subscription filtering.
+func recordEvent(kind string, rec store.PRCommitRecord) store.FilterEvent {
+	return store.FilterEvent{
+		Kind:         kind,
+		TargetBranch: rec.DestBranch,
+		SourceBranch: rec.SourceBranch,
+		Author:       rec.AuthorName,
+		Paths:        rec.ChangedPaths,
+	}
+}
+
+// prEvent describes a PR webhook event for subscription filtering, taking the PR's
+// changed files from the stored record.
+func (h *WebhookHandler) prEvent(ctx context.Context, kind string, p bbEventPayload) store.FilterEvent {
+	ev := recordEvent(kind, prCommitRecord(p))
+	stored, err := h.repoStore.GetPRCommit(ctx, p.Repository.FullName, p.PullRequest.ID)
+	if err != nil {
+		h.log.Error("get PR commit", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "err", err)
+	}
+	if stored != nil {
+		ev.Paths = stored.ChangedPaths
+	}
+	return ev
+}
@@ -1,6 +1,7 @@
 package bitbucket
 
 import (
+	"cmp"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
@@ -8,32 +9,54 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
+	"bitbucket-slack-bot/internal/provider"
+	slackbot "bitbucket-slack-bot/internal/slack"
 	"bitbucket-slack-bot/internal/store"
 
 	"github.com/gofiber/fiber/v2"
 	slacklib "github.com/slack-go/slack"
 )
 
+// deliveryTTL is how long a delivery's X-Request-UUID is remembered for deduplication.
+// Bitbucket stops redelivering well within this window.
+const deliveryTTL = 72 * time.Hour
+
 // WebhookHandler processes incoming Bitbucket webhook events and forwards
 // PR notifications to all Slack channels subscribed to that repository.
+// All Slack messages are queued in the outbox rather than posted inline.
 type WebhookHandler struct {
-	slack     *slacklib.Client
-	repoStore *store.RepoStore
-	log       *slog.Logger
+	outbox        *slackbot.Outbox
+	repoStore     *store.RepoStore
+	webhookSecret func(ctx context.Context, slug string) (string, error)
+	providerFor   func(ctx context.Context, teamID string) (provider.Provider, error)
+	refreshHome   func(ctx context.Context, teamIDs, bitbucketNames []string)
+	log           *slog.Logger
 }
 
-func NewWebhookHandler(slack *slacklib.Client, repoStore *store.RepoStore, log *slog.Logger) *WebhookHandler {
-	return &WebhookHandler{slack: slack, repoStore: repoStore, log: log}
+// NewWebhookHandler creates a webhook handler. providerFor returns a Bitbucket client for a
+// Slack team (nil if it has no connection); it is used to fetch PR diffstats and CODEOWNERS.
+// refreshHome republishes the App Home of the given users of the given teams after their PRs change.
+func NewWebhookHandler(outbox *slackbot.Outbox, repoStore *store.RepoStore, providerFor func(ctx context.Context, teamID string) (provider.Provider, error), refreshHome func(ctx context.Context, teamIDs, bitbucketNames []string), log *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		outbox:        outbox,
+		repoStore:     repoStore,
+		webhookSecret: repoStore.GetWebhookSecret,
+		providerFor:   providerFor,
+		refreshHome:   refreshHome,
+		log:           log,
+	}
 }
 
-// resolveUser looks up the Slack user ID for a Bitbucket display name.
+// resolveUser looks up the Slack user of teamID linked to a Bitbucket display name.
 // Returns "<@USERID>" if a mapping exists, or "*DisplayName*" otherwise.
-func (h *WebhookHandler) resolveUser(ctx context.Context, displayName string) string {
-	id, err := h.repoStore.GetSlackUserByBitbucket(ctx, displayName)
+func (h *WebhookHandler) resolveUser(ctx context.Context, teamID, displayName string) string {
+	id, err := h.repoStore.GetSlackUserByBitbucket(ctx, teamID, displayName)
 	if err != nil {
-		h.log.Warn("resolve user", "bitbucket", displayName, "err", err)
+		h.log.Warn("resolve user", "team", teamID, "bitbucket", displayName, "err", err)
 	}
 	if id != "" {
 		return "<@" + id + ">"
@@ -43,19 +66,21 @@ func (h *WebhookHandler) resolveUser(ctx context.Context, displayName string) st
 
 // resolveReviewers resolves all PR reviewers to Slack mentions joined with ", ".
 // Returns "—" when there are no reviewers.
-func (h *WebhookHandler) resolveReviewers(ctx context.Context, pr bbPullRequest) string {
+func (h *WebhookHandler) resolveReviewers(ctx context.Context, teamID string, pr bbPullRequest) string {
 	if len(pr.Reviewers) == 0 {
 		return "—"
 	}
 	names := make([]string, len(pr.Reviewers))
 	for i, r := range pr.Reviewers {
-		names[i] = h.resolveUser(ctx, r.DisplayName)
+		names[i] = h.resolveUser(ctx, teamID, r.DisplayName)
 	}
 	return strings.Join(names, ", ")
 }
 
 // prCard holds all data needed to build a PR Slack card.
 type prCard struct {
+	prID         int
+	open         bool // show the Approve and Request changes buttons
 	title        string
 	prURL        string
 	repoFullName string
@@ -65,6 +90,7 @@ type prCard struct {
 	reviewers    string
 	buildLabel   string
 	statusLine   string
+	changesLine  string
 }
 
 // getBuildLabel fetches the current build status from DB and formats it.
@@ -105,12 +131,12 @@ func formatBuildLabel(state, name, url string) string {
 	return fmt.Sprintf("%s %s", emoji, name)
 }
 
-// buildCardFromPayload constructs a prCard from a PR webhook event payload,
+// buildCardFromPayload constructs teamID's prCard from a PR webhook event payload,
 // looking up the current build status from DB. Falls back to DB commit hash
 // if the payload does not include one.
-func (h *WebhookHandler) buildCardFromPayload(ctx context.Context, p bbEventPayload, statusLine string) prCard {
-	author := h.resolveUser(ctx, p.PullRequest.Author.DisplayName)
-	reviewers := h.resolveReviewers(ctx, p.PullRequest)
+func (h *WebhookHandler) buildCardFromPayload(ctx context.Context, teamID string, p bbEventPayload, statusLine string) prCard {
+	author := h.resolveUser(ctx, teamID, p.PullRequest.Author.DisplayName)
+	reviewers := h.resolveReviewers(ctx, teamID, p.PullRequest)
 
 	commitHash := p.PullRequest.Source.Commit.Hash
 	if commitHash == "" {
@@ -120,6 +146,8 @@ func (h *WebhookHandler) buildCardFromPayload(ctx context.Context, p bbEventPayl
 	}
 
 	return prCard{
+		prID:         p.PullRequest.ID,
+		open:         isOpenState(p.PullRequest.State),
 		title:        p.PullRequest.Title,
 		prURL:        p.PullRequest.Links.HTML.Href,
 		repoFullName: p.Repository.FullName,
@@ -129,6 +157,32 @@ func (h *WebhookHandler) buildCardFromPayload(ctx context.Context, p bbEventPayl
 		reviewers:    reviewers,
 		buildLabel:   h.getBuildLabel(ctx, p.Repository.FullName, commitHash),
 		statusLine:   statusLine,
+		changesLine:  h.changesRequestedStatus(ctx, teamID, p.Repository.FullName, p.PullRequest.ID),
+	}
+}
+
+// webhookSecrets returns the signing secrets configured for repoSlug's own webhook and
+// for its workspace webhook, skipping any that are not set.
+func (h *WebhookHandler) webhookSecrets(ctx context.Context, repoSlug string) ([]string, error) {
+	workspace, _, _ := strings.Cut(repoSlug, "/")
+	var secrets []string
+	for _, slug := range []string{repoSlug, store.WorkspaceSlug(workspace)} {
+		secret, err := h.webhookSecret(ctx, slug)
+		if err != nil {
+			return nil, err
+		}
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets, nil
+}
+
+// PurgeDeliveries forgets webhook deliveries older than deliveryTTL. It is registered
+// with the scheduler.
+func (h *WebhookHandler) PurgeDeliveries(ctx context.Context, now time.Time) {
+	if _, err := h.repoStore.PurgeWebhookDeliveries(ctx, now.Add(-deliveryTTL)); err != nil && ctx.Err() == nil {
+		h.log.Error("purge webhook deliveries", "err", err)
 	}
 }
 
@@ -137,137 +191,357 @@ func (h *WebhookHandler) Handle(c *fiber.Ctx) error {
 	event := c.Get("X-Event-Key")
 	h.log.Info("bitbucket webhook received", "event", event)
 
-	switch event {
-	case "pullrequest:created",
-		"pullrequest:fulfilled",
-		"pullrequest:rejected",
-		"pullrequest:approved",
-		"pullrequest:unapproved",
-		"pullrequest:comment_created",
-		"repo:commit_status_created",
-		"repo:commit_status_updated":
-		// handled below
-	default:
+	if !slices.Contains(provider.WebhookEvents, event) {
 		h.log.Info("ignoring event", "event", event)
 		return c.SendStatus(fiber.StatusOK)
 	}
 
 	body := c.Body()
 
-	// Commit status events have a different payload shape — route early.
-	if event == "repo:commit_status_created" || event == "repo:commit_status_updated" {
-		var p bbCommitStatusPayload
-		if err := json.Unmarshal(body, &p); err != nil {
-			h.log.Error("parse commit status payload", "err", err)
-			return c.Status(fiber.StatusBadRequest).SendString("invalid payload")
-		}
-		go h.onCommitStatus(p)
-		return c.SendStatus(fiber.StatusOK)
+	// Every event family carries repository.full_name — read it first so the
+	// signature check runs before any payload is acted upon.
+	var envelope struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
 	}
-
-	var payload bbEventPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
+	if err := json.Unmarshal(body, &envelope); err != nil {
 		h.log.Error("parse bitbucket webhook", "err", err)
 		return c.Status(fiber.StatusBadRequest).SendString("invalid payload")
 	}
+	repoSlug := envelope.Repository.FullName
 
-	// Verify HMAC signature if a secret is configured for this repo.
-	secret, err := h.repoStore.GetWebhookSecret(c.Context(), payload.Repository.FullName)
+	// Verify the HMAC signature if a secret is configured for this repo or, for
+	// workspace webhooks, for its workspace. Either one may have signed it.
+	secrets, err := h.webhookSecrets(c.Context(), repoSlug)
 	if err != nil {
-		h.log.Error("get webhook secret", "repo", payload.Repository.FullName, "err", err)
+		h.log.Error("get webhook secret", "repo", repoSlug, "err", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("internal error")
 	}
-	if secret != "" {
-		if !verifySignature(secret, body, c.Get("X-Hub-Signature")) {
-			h.log.Warn("webhook signature mismatch", "repo", payload.Repository.FullName)
+	if len(secrets) > 0 {
+		signature := c.Get("X-Hub-Signature")
+		if !slices.ContainsFunc(secrets, func(s string) bool { return verifySignature(s, body, signature) }) {
+			h.log.Warn("webhook signature mismatch", "repo", repoSlug, "event", event)
 			return c.Status(fiber.StatusUnauthorized).SendString("invalid signature")
 		}
 	}
 
+	// Parse before recording the delivery, so a payload we cannot read is not
+	// remembered and skipped when Bitbucket redelivers it.
+	var status bbCommitStatusPayload
+	var payload bbEventPayload
+	isStatus := event == "repo:commit_status_created" || event == "repo:commit_status_updated"
+	if isStatus {
+		err = json.Unmarshal(body, &status)
+	} else {
+		err = json.Unmarshal(body, &payload)
+	}
+	if err != nil {
+		h.log.Error("parse bitbucket webhook", "event", event, "err", err)
+		return c.Status(fiber.StatusBadRequest).SendString("invalid payload")
+	}
+
+	// Bitbucket redelivers on timeouts and errors; acknowledge deliveries we have already seen.
+	// A repository covered by both its own webhook and a workspace webhook also gets every
+	// event twice, under different request UUIDs but with identical bodies.
+	contentSum := sha256.Sum256(append([]byte(event+"\n"), body...))
+	deliveryKeys := []string{"sha256:" + hex.EncodeToString(contentSum[:])}
+	if requestUUID := c.Get("X-Request-UUID"); requestUUID != "" {
+		deliveryKeys = append([]string{requestUUID}, deliveryKeys...)
+	}
+	for i, key := range deliveryKeys {
+		fresh, err := h.repoStore.RecordWebhookDelivery(c.Context(), key, event, c.Get("X-Hook-UUID"))
+		if err != nil {
+			h.log.Error("record webhook delivery", "key", key, "err", err)
+			// Forget the keys already recorded so the redelivery is not taken for a duplicate.
+			if err := h.repoStore.ForgetWebhookDeliveries(c.Context(), deliveryKeys[:i]); err != nil {
+				h.log.Error("forget webhook delivery", "keys", deliveryKeys[:i], "err", err)
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("internal error")
+		}
+		if !fresh {
+			h.log.Info("duplicate webhook delivery", "key", key, "event", event, "repo", repoSlug)
+			return c.SendStatus(fiber.StatusOK)
+		}
+	}
+
+	// Commit status events have a different payload shape — route early.
+	if isStatus {
+		go h.onCommitStatus(status)
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	// Keep the cached lifecycle state current for digests and reminders, whatever the event.
+	if pr := payload.PullRequest; pr.State != "" {
+		if err := h.repoStore.TouchPRCommit(c.Context(), repoSlug, pr.ID, pr.State, pr.UpdatedOn); err != nil {
+			h.log.Error("touch PR commit", "repo", repoSlug, "pr", pr.ID, "err", err)
+		}
+	}
+
+	var handle func(bbEventPayload)
 	switch event {
 	case "pullrequest:created":
 		h.log.Info("PR created", "repo", payload.Repository.FullName, "pr_id", payload.PullRequest.ID, "title", payload.PullRequest.Title)
-		go h.onPRCreated(payload)
+		handle = h.onPRCreated
+	case "pullrequest:updated":
+		h.log.Info("PR updated", "repo", payload.Repository.FullName, "pr_id", payload.PullRequest.ID)
+		handle = h.onPRUpdated
 	case "pullrequest:fulfilled":
 		h.log.Info("PR merged", "repo", payload.Repository.FullName, "pr_id", payload.PullRequest.ID)
-		go h.onPRMerged(payload)
+		handle = h.onPRMerged
 	case "pullrequest:rejected":
 		h.log.Info("PR declined", "repo", payload.Repository.FullName, "pr_id", payload.PullRequest.ID)
-		go h.onPRDeclined(payload)
+		handle = h.onPRDeclined
 	case "pullrequest:approved":
-		go h.onPRApproved(payload)
+		handle = h.onPRApproved
 	case "pullrequest:unapproved":
-		go h.onPRUnapproved(payload)
+		handle = h.onPRUnapproved
+	case "pullrequest:changes_request_created":
+		handle = h.onPRChangesRequested
+	case "pullrequest:changes_request_removed":
+		handle = h.onPRChangesRequestRemoved
 	case "pullrequest:comment_created":
+		// Comments change nothing the App Home shows, so no refresh.
 		go h.onPRComment(payload)
 	}
+	if handle != nil {
+		go func() {
+			handle(payload)
+			h.refreshHomesForPR(context.Background(), payload.Repository.FullName, payload.PullRequest.ID)
+		}()
+	}
 
 	return c.SendStatus(fiber.StatusOK)
 }
 
+// refreshHomesForPR republishes the App Home of the PR's author and reviewers in every
+// team subscribed to its repository.
+func (h *WebhookHandler) refreshHomesForPR(ctx context.Context, repoSlug string, prID int) {
+	if h.refreshHome == nil {
+		return
+	}
+	rec, err := h.repoStore.GetPRCommit(ctx, repoSlug, prID)
+	if err != nil || rec == nil {
+		return
+	}
+	teams, err := h.subscribedTeams(ctx, repoSlug)
+	if err != nil {
+		h.log.Error("look up channels for repo", "repo", repoSlug, "err", err)
+		return
+	}
+	if len(teams) > 0 {
+		h.refreshHome(ctx, teams, append([]string{rec.AuthorName}, rec.ReviewerNames...))
+	}
+}
+
+// subscribedTeams returns the Slack teams with a channel subscribed to repoSlug.
+func (h *WebhookHandler) subscribedTeams(ctx context.Context, repoSlug string) ([]string, error) {
+	subs, err := h.repoStore.ChannelsForRepo(ctx, repoSlug)
+	if err != nil {
+		return nil, err
+	}
+	var teams []string
+	for _, sub := range subs {
+		if !slices.Contains(teams, sub.TeamID) {
+			teams = append(teams, sub.TeamID)
+		}
+	}
+	return teams, nil
+}
+
 // onPRCreated posts the initial PR notification and saves the message ts + PR commit info.
+// The PR's changed files are fetched for path-filtered subscriptions and to mention code owners.
 func (h *WebhookHandler) onPRCreated(p bbEventPayload) {
 	ctx := context.Background()
-	channels, err := h.repoStore.ChannelsForRepo(ctx, p.Repository.FullName)
+	subs, err := h.repoStore.ChannelsForRepo(ctx, p.Repository.FullName)
 	if err != nil {
 		h.log.Error("look up channels for repo", "repo", p.Repository.FullName, "err", err)
 		return
 	}
-	if len(channels) == 0 {
+	if len(subs) == 0 {
 		h.log.Info("no subscribers for repo", "repo", p.Repository.FullName)
 		return
 	}
 
-	card := h.buildCardFromPayload(ctx, p, "")
-	blocks := buildPRBlocks(card)
+	rec := prCommitRecord(p)
+	git := h.gitForRepo(ctx, rec.RepoSlug, subs)
+	if git != nil {
+		rec.ChangedPaths = h.changedPaths(ctx, git, rec.RepoSlug, rec.PRID)
+	}
 
 	// Persist PR commit info so pipeline status events can find this PR later.
-	reviewerNames := make([]string, len(p.PullRequest.Reviewers))
-	for i, r := range p.PullRequest.Reviewers {
-		reviewerNames[i] = r.DisplayName
-	}
-	if err := h.repoStore.SavePRCommit(ctx, store.PRCommitRecord{
-		RepoSlug:      p.Repository.FullName,
-		PRID:          p.PullRequest.ID,
-		CommitHash:    p.PullRequest.Source.Commit.Hash,
-		Title:         p.PullRequest.Title,
-		URL:           p.PullRequest.Links.HTML.Href,
-		AuthorName:    p.PullRequest.Author.DisplayName,
-		ReviewerNames: reviewerNames,
-		SourceBranch:  p.PullRequest.Source.Branch.Name,
-		DestBranch:    p.PullRequest.Destination.Branch.Name,
-	}); err != nil {
+	if err := h.repoStore.SavePRCommit(ctx, rec); err != nil {
 		h.log.Error("save PR commit", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "err", err)
 	}
 
-	for _, channelID := range channels {
-		_, ts, err := h.slack.PostMessage(channelID, slacklib.MsgOptionBlocks(blocks...))
-		if err != nil {
-			h.log.Error("post PR notification", "channel", channelID, "err", err)
+	var owners []string
+	if git != nil {
+		owners = h.codeOwners(ctx, git, rec, p.PullRequest.Author)
+	}
+
+	for _, name := range rec.ReviewerNames {
+		h.notifyUser(ctx, store.NotifyReviewerAdded, name, rec.AuthorName, rec, func(author string) string {
+			return fmt.Sprintf(":eyes: %s added you as a reviewer on %s", author, dmPRLink(rec))
+		})
+	}
+
+	render := perTeam(func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		var reply string
+		if len(owners) > 0 {
+			reply = ":busts_in_silhouette: Code owners: " + h.resolveOwners(ctx, teamID, owners)
+		}
+		return buildPRBlocks(h.buildCardFromPayload(ctx, teamID, p, "")), reply
+	})
+	ev := recordEvent(store.EventCreated, rec)
+	queued := 0
+	for _, sub := range subs {
+		if !sub.Filter.Allows(ev) {
 			continue
 		}
-		if err := h.repoStore.SavePRMessage(ctx, p.Repository.FullName, p.PullRequest.ID, channelID, ts); err != nil {
-			h.log.Error("save PR message ts", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "err", err)
+		blocks, ownersReply := render(ctx, sub.TeamID)
+		if err := h.outbox.PostCard(ctx, sub.TeamID, sub.ChannelID, p.Repository.FullName, p.PullRequest.ID, blocks); err != nil {
+			h.log.Error("queue PR notification", "channel", sub.ChannelID, "err", err)
+			continue
 		}
+		queued++
+		if ownersReply != "" {
+			if err := h.outbox.Reply(ctx, sub.TeamID, sub.ChannelID, p.Repository.FullName, p.PullRequest.ID, ownersReply); err != nil {
+				h.log.Error("queue code owners reply", "channel", sub.ChannelID, "err", err)
+			}
+		}
+	}
+
+	h.log.Info("PR notification queued", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "channels", queued)
+}
+
+// onPRUpdated refreshes the stored PR info so build status events keep matching the
+// latest head commit, re-renders the card, and posts a thread reply summarising the change.
+func (h *WebhookHandler) onPRUpdated(p bbEventPayload) {
+	ctx := context.Background()
+	repoSlug, prID := p.Repository.FullName, p.PullRequest.ID
+
+	prev, err := h.repoStore.GetPRCommit(ctx, repoSlug, prID)
+	if err != nil {
+		h.log.Error("get PR commit", "repo", repoSlug, "pr", prID, "err", err)
+	}
+
+	rec := prCommitRecord(p)
+	if prev != nil {
+		if rec.CommitHash == "" {
+			rec.CommitHash = prev.CommitHash
+		}
+		rec.ChangedPaths = prev.ChangedPaths
+	}
+	// New commits can touch different files; refetch so path filters stay accurate.
+	if prev == nil || prev.CommitHash != rec.CommitHash || prev.ChangedPaths == nil {
+		if git := h.gitForRepo(ctx, repoSlug, nil); git != nil {
+			if paths := h.changedPaths(ctx, git, repoSlug, prID); paths != nil {
+				rec.ChangedPaths = paths
+			}
+		}
+	}
+	if err := h.repoStore.SavePRCommit(ctx, rec); err != nil {
+		h.log.Error("save PR commit", "repo", repoSlug, "pr", prID, "err", err)
+	}
+
+	card := func(ctx context.Context, teamID string) []slacklib.Block {
+		return buildPRBlocks(h.buildCardFromPayload(ctx, teamID, p, h.approvalStatus(ctx, teamID, repoSlug, prID)))
+	}
+
+	// Without a previous snapshot there is nothing to diff against — just refresh the card.
+	if prev == nil {
+		h.updateCard(repoSlug, prID, card)
+		return
+	}
+
+	added, _ := diffNames(prev.ReviewerNames, rec.ReviewerNames)
+	for _, name := range added {
+		h.notifyUser(ctx, store.NotifyReviewerAdded, name, p.Actor.DisplayName, rec, func(actor string) string {
+			return fmt.Sprintf(":eyes: %s added you as a reviewer on %s", actor, dmPRLink(rec))
+		})
+	}
+
+	if !prChanged(*prev, rec) {
+		h.updateCard(repoSlug, prID, card)
+		return
+	}
+
+	h.updateAndReply(repoSlug, prID, recordEvent(store.EventUpdated, rec), func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		changes := h.describePRChanges(ctx, teamID, *prev, rec)
+		reply := fmt.Sprintf(":pencil2: %s updated this PR:\n• %s", h.resolveUser(ctx, teamID, p.Actor.DisplayName), strings.Join(changes, "\n• "))
+		return card(ctx, teamID), reply
+	})
+}
+
+// prChanged reports whether describePRChanges has anything to say about cur.
+func prChanged(prev, cur store.PRCommitRecord) bool {
+	added, removed := diffNames(prev.ReviewerNames, cur.ReviewerNames)
+	return cur.CommitHash != "" && cur.CommitHash != prev.CommitHash || cur.Title != prev.Title ||
+		len(added) > 0 || len(removed) > 0
+}
+
+// describePRChanges returns one human-readable line per difference between two
+// snapshots of the same PR: new head commit, title change, reviewers added or removed.
+// Reviewers are mentioned as teamID's users.
+func (h *WebhookHandler) describePRChanges(ctx context.Context, teamID string, prev, cur store.PRCommitRecord) []string {
+	var changes []string
+
+	if cur.CommitHash != "" && cur.CommitHash != prev.CommitHash {
+		changes = append(changes, fmt.Sprintf("New commits pushed, head is now `%s`", shortHash(cur.CommitHash)))
+	}
+	if cur.Title != prev.Title {
+		changes = append(changes, fmt.Sprintf("Title changed from _%s_ to _%s_", prev.Title, cur.Title))
+	}
+
+	added, removed := diffNames(prev.ReviewerNames, cur.ReviewerNames)
+	if len(added) > 0 {
+		changes = append(changes, "Reviewers added: "+h.resolveUsers(ctx, teamID, added))
+	}
+	if len(removed) > 0 {
+		changes = append(changes, "Reviewers removed: "+h.resolveUsers(ctx, teamID, removed))
+	}
+
+	return changes
+}
+
+// resolveUsers resolves display names to Slack mentions of teamID's users joined with ", ".
+func (h *WebhookHandler) resolveUsers(ctx context.Context, teamID string, names []string) string {
+	labels := make([]string, len(names))
+	for i, name := range names {
+		labels[i] = h.resolveUser(ctx, teamID, name)
 	}
+	return strings.Join(labels, ", ")
+}
 
-	h.log.Info("PR notification sent", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "channels", len(channels))
+// approvalStatus returns the approvers status line for a PR based on the stored approvals.
+func (h *WebhookHandler) approvalStatus(ctx context.Context, teamID, repoSlug string, prID int) string {
+	approvers, err := h.repoStore.GetApprovals(ctx, repoSlug, prID)
+	if err != nil {
+		h.log.Error("get approvals", "repo", repoSlug, "pr", prID, "err", err)
+	}
+	resolved := make([]string, len(approvers))
+	for i, a := range approvers {
+		resolved[i] = h.resolveUser(ctx, teamID, a)
+	}
+	return buildApprovalStatus(resolved)
 }
 
 // onPRMerged updates the original message and posts a thread reply.
 func (h *WebhookHandler) onPRMerged(p bbEventPayload) {
 	ctx := context.Background()
-	actor := h.resolveUser(ctx, p.Actor.DisplayName)
-	card := h.buildCardFromPayload(ctx, p, fmt.Sprintf(":tada: Merged by %s", actor))
-	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, buildPRBlocks(card), card.statusLine)
+	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, h.prEvent(ctx, store.EventMerged, p), func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		card := h.buildCardFromPayload(ctx, teamID, p, fmt.Sprintf(":tada: Merged by %s", h.resolveUser(ctx, teamID, p.Actor.DisplayName)))
+		return buildPRBlocks(card), card.statusLine
+	})
 }
 
 // onPRDeclined updates the original message and posts a thread reply.
 func (h *WebhookHandler) onPRDeclined(p bbEventPayload) {
 	ctx := context.Background()
-	actor := h.resolveUser(ctx, p.Actor.DisplayName)
-	card := h.buildCardFromPayload(ctx, p, fmt.Sprintf(":x: Declined by %s", actor))
-	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, buildPRBlocks(card), card.statusLine)
+	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, h.prEvent(ctx, store.EventDeclined, p), func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		card := h.buildCardFromPayload(ctx, teamID, p, fmt.Sprintf(":x: Declined by %s", h.resolveUser(ctx, teamID, p.Actor.DisplayName)))
+		return buildPRBlocks(card), card.statusLine
+	})
 }
 
 // onPRApproved records the approval, rebuilds the approvers context block, and posts a thread reply.
@@ -276,19 +550,15 @@ func (h *WebhookHandler) onPRApproved(p bbEventPayload) {
 	if err := h.repoStore.AddApproval(ctx, p.Repository.FullName, p.PullRequest.ID, p.Actor.DisplayName); err != nil {
 		h.log.Error("add approval", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "err", err)
 	}
-	approvers, err := h.repoStore.GetApprovals(ctx, p.Repository.FullName, p.PullRequest.ID)
-	if err != nil {
-		h.log.Error("get approvals", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "err", err)
-	}
 
-	resolved := make([]string, len(approvers))
-	for i, a := range approvers {
-		resolved[i] = h.resolveUser(ctx, a)
-	}
-	actor := h.resolveUser(ctx, p.Actor.DisplayName)
-	card := h.buildCardFromPayload(ctx, p, buildApprovalStatus(resolved))
-	reply := fmt.Sprintf(":white_check_mark: %s approved this PR", actor)
-	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, buildPRBlocks(card), reply)
+	rec := prCommitRecord(p)
+	h.notifyUser(ctx, store.NotifyApproved, rec.AuthorName, p.Actor.DisplayName, rec, func(actor string) string {
+		return fmt.Sprintf(":white_check_mark: %s approved your pull request %s", actor, dmPRLink(rec))
+	})
+	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, h.prEvent(ctx, store.EventApproved, p), func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		card := h.buildCardFromPayload(ctx, teamID, p, h.approvalStatus(ctx, teamID, p.Repository.FullName, p.PullRequest.ID))
+		return buildPRBlocks(card), fmt.Sprintf(":white_check_mark: %s approved this PR", h.resolveUser(ctx, teamID, p.Actor.DisplayName))
+	})
 }
 
 // onPRUnapproved removes the approval, rebuilds the approvers context block, and posts a thread reply.
@@ -297,19 +567,41 @@ func (h *WebhookHandler) onPRUnapproved(p bbEventPayload) {
 	if err := h.repoStore.RemoveApproval(ctx, p.Repository.FullName, p.PullRequest.ID, p.Actor.DisplayName); err != nil {
 		h.log.Error("remove approval", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "err", err)
 	}
-	approvers, err := h.repoStore.GetApprovals(ctx, p.Repository.FullName, p.PullRequest.ID)
-	if err != nil {
-		h.log.Error("get approvals", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "err", err)
+
+	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, h.prEvent(ctx, store.EventUnapproved, p), func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		card := h.buildCardFromPayload(ctx, teamID, p, h.approvalStatus(ctx, teamID, p.Repository.FullName, p.PullRequest.ID))
+		return buildPRBlocks(card), fmt.Sprintf(":leftwards_arrow_with_hook: %s removed their approval", h.resolveUser(ctx, teamID, p.Actor.DisplayName))
+	})
+}
+
+// onPRChangesRequested records the "request changes" review, rebuilds the card status block, and posts a thread reply.
+func (h *WebhookHandler) onPRChangesRequested(p bbEventPayload) {
+	ctx := context.Background()
+	if err := h.repoStore.AddChangeRequest(ctx, p.Repository.FullName, p.PullRequest.ID, p.Actor.DisplayName); err != nil {
+		h.log.Error("add change request", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "err", err)
 	}
 
-	resolved := make([]string, len(approvers))
-	for i, a := range approvers {
-		resolved[i] = h.resolveUser(ctx, a)
+	rec := prCommitRecord(p)
+	h.notifyUser(ctx, store.NotifyChangesRequested, rec.AuthorName, p.Actor.DisplayName, rec, func(actor string) string {
+		return fmt.Sprintf(":warning: %s requested changes on your pull request %s", actor, dmPRLink(rec))
+	})
+	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, h.prEvent(ctx, store.EventChangesRequested, p), func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		card := h.buildCardFromPayload(ctx, teamID, p, h.approvalStatus(ctx, teamID, p.Repository.FullName, p.PullRequest.ID))
+		return buildPRBlocks(card), fmt.Sprintf(":warning: %s requested changes on this PR", h.resolveUser(ctx, teamID, p.Actor.DisplayName))
+	})
+}
+
+// onPRChangesRequestRemoved removes the "request changes" review, rebuilds the card status block, and posts a thread reply.
+func (h *WebhookHandler) onPRChangesRequestRemoved(p bbEventPayload) {
+	ctx := context.Background()
+	if err := h.repoStore.RemoveChangeRequest(ctx, p.Repository.FullName, p.PullRequest.ID, p.Actor.DisplayName); err != nil {
+		h.log.Error("remove change request", "repo", p.Repository.FullName, "pr", p.PullRequest.ID, "err", err)
 	}
-	actor := h.resolveUser(ctx, p.Actor.DisplayName)
-	card := h.buildCardFromPayload(ctx, p, buildApprovalStatus(resolved))
-	reply := fmt.Sprintf(":leftwards_arrow_with_hook: %s removed their approval", actor)
-	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, buildPRBlocks(card), reply)
+
+	h.updateAndReply(p.Repository.FullName, p.PullRequest.ID, h.prEvent(ctx, store.EventChangesRequested, p), func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		card := h.buildCardFromPayload(ctx, teamID, p, h.approvalStatus(ctx, teamID, p.Repository.FullName, p.PullRequest.ID))
+		return buildPRBlocks(card), fmt.Sprintf(":leftwards_arrow_with_hook: %s withdrew their change request", h.resolveUser(ctx, teamID, p.Actor.DisplayName))
+	})
 }
 
 // onPRComment posts the comment text as a thread reply.
@@ -319,9 +611,19 @@ func (h *WebhookHandler) onPRComment(p bbEventPayload) {
 	if len(text) > 300 {
 		text = text[:300] + "…"
 	}
-	actor := h.resolveUser(ctx, p.Actor.DisplayName)
-	reply := fmt.Sprintf(":speech_balloon: %s commented:\n>%s", actor, text)
-	h.threadReply(p.Repository.FullName, p.PullRequest.ID, reply)
+	rec := prCommitRecord(p)
+	h.notifyUser(ctx, store.NotifyComment, rec.AuthorName, p.Actor.DisplayName, rec, func(actor string) string {
+		return fmt.Sprintf(":speech_balloon: %s commented on your pull request %s:\n>%s", actor, dmPRLink(rec), text)
+	})
+
+	// A comment sent from a Slack thread is already in that thread; show it everywhere else.
+	origin, err := h.repoStore.SyncedCommentChannel(ctx, p.Repository.FullName, p.PullRequest.ID, p.Comment.ID, p.Comment.Content.Raw)
+	if err != nil {
+		h.log.Error("look up synced comment", "repo", p.Repository.FullName, "comment", p.Comment.ID, "err", err)
+	}
+	h.threadReplyExcept(p.Repository.FullName, p.PullRequest.ID, h.prEvent(ctx, store.EventComment, p), func(ctx context.Context, teamID string) string {
+		return fmt.Sprintf(":speech_balloon: %s commented:\n>%s", h.resolveUser(ctx, teamID, p.Actor.DisplayName), text)
+	}, origin)
 }
 
 // onCommitStatus saves the build status, updates all Slack PR cards for that commit,
@@ -345,6 +647,7 @@ func (h *WebhookHandler) onCommitStatus(p bbCommitStatusPayload) {
 
 	buildLabel := formatBuildLabel(p.CommitStatus.State, p.CommitStatus.Name, p.CommitStatus.URL)
 	replyText := buildStatusReply(p.CommitStatus.State, p.CommitStatus.Name, p.CommitStatus.URL)
+	kind := buildEventKind(p.CommitStatus.State)
 
 	for _, prID := range prIDs {
 		rec, err := h.repoStore.GetPRCommit(ctx, repoSlug, prID)
@@ -352,55 +655,53 @@ func (h *WebhookHandler) onCommitStatus(p bbCommitStatusPayload) {
 			continue
 		}
 
-		author := h.resolveUser(ctx, rec.AuthorName)
-		reviewers := "—"
-		if len(rec.ReviewerNames) > 0 {
-			labels := make([]string, len(rec.ReviewerNames))
-			for i, name := range rec.ReviewerNames {
-				labels[i] = h.resolveUser(ctx, name)
-			}
-			reviewers = strings.Join(labels, ", ")
-		}
-
-		approvers, _ := h.repoStore.GetApprovals(ctx, repoSlug, prID)
-		resolved := make([]string, len(approvers))
-		for i, a := range approvers {
-			resolved[i] = h.resolveUser(ctx, a)
-		}
-
-		card := prCard{
-			title:        rec.Title,
-			prURL:        rec.URL,
-			repoFullName: repoSlug,
-			sourceBranch: rec.SourceBranch,
-			destBranch:   rec.DestBranch,
-			authorLabel:  author,
-			reviewers:    reviewers,
-			buildLabel:   buildLabel,
-			statusLine:   buildApprovalStatus(resolved),
-		}
-
-		msgs, err := h.repoStore.GetPRMessages(ctx, repoSlug, prID)
-		if err != nil {
-			h.log.Error("get PR messages", "repo", repoSlug, "pr", prID, "err", err)
-			continue
-		}
-		blocks := buildPRBlocks(card)
-		for _, msg := range msgs {
-			if _, _, _, err := h.slack.UpdateMessage(msg.ChannelID, msg.MessageTS, slacklib.MsgOptionBlocks(blocks...)); err != nil {
-				h.log.Error("update PR message on build status", "channel", msg.ChannelID, "err", err)
+		ev := recordEvent(kind, *rec)
+		h.queueForPR(ctx, repoSlug, prID, ev, perTeam(func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+			reviewers := "—"
+			if len(rec.ReviewerNames) > 0 {
+				reviewers = h.resolveUsers(ctx, teamID, rec.ReviewerNames)
 			}
-			if _, _, err := h.slack.PostMessage(msg.ChannelID,
-				slacklib.MsgOptionTS(msg.MessageTS),
-				slacklib.MsgOptionText(replyText, false),
-			); err != nil {
-				h.log.Error("post build status thread reply", "channel", msg.ChannelID, "err", err)
+			card := prCard{
+				prID:         prID,
+				open:         isOpenState(rec.State),
+				title:        rec.Title,
+				prURL:        rec.URL,
+				repoFullName: repoSlug,
+				sourceBranch: rec.SourceBranch,
+				destBranch:   rec.DestBranch,
+				authorLabel:  h.resolveUser(ctx, teamID, rec.AuthorName),
+				reviewers:    reviewers,
+				buildLabel:   buildLabel,
+				statusLine:   h.approvalStatus(ctx, teamID, repoSlug, prID),
+				changesLine:  h.changesRequestedStatus(ctx, teamID, repoSlug, prID),
 			}
+			return buildPRBlocks(card), replyText
+		}))
+		h.refreshHomesForPR(ctx, repoSlug, prID)
+		if kind == store.EventBuildFailed {
+			h.notifyUser(ctx, store.NotifyBuildFailed, rec.AuthorName, "", *rec, func(string) string {
+				return fmt.Sprintf("%s on your pull request %s", replyText, dmPRLink(*rec))
+			})
 		}
 		h.log.Info("PR card updated for build status", "repo", repoSlug, "pr", prID, "state", p.CommitStatus.State)
 	}
 }
 
+// buildEventKind maps a commit status state to its subscription filter event kind.
+func buildEventKind(state string) string {
+	switch strings.ToUpper(state) {
+	case "INPROGRESS":
+		return store.EventBuildStarted
+	case "SUCCESSFUL":
+		return store.EventBuildPassed
+	case "FAILED":
+		return store.EventBuildFailed
+	case "STOPPED":
+		return store.EventBuildStopped
+	}
+	return ""
+}
+
 // buildStatusReply formats a build state/name/url into a thread-reply string.
 func buildStatusReply(state, name, url string) string {
 	var prefix string
@@ -433,55 +734,129 @@ func buildApprovalStatus(resolved []string) string {
 	return ":white_check_mark: Approved by " + strings.Join(resolved, ", ")
 }
 
-// updateAndReply updates the original Slack message and posts a thread reply.
-// Falls back to a new standalone message if no ts is stored.
-func (h *WebhookHandler) updateAndReply(repoSlug string, prID int, blocks []slacklib.Block, replyText string) {
-	ctx := context.Background()
-	msgs, err := h.repoStore.GetPRMessages(ctx, repoSlug, prID)
+// changesRequestedStatus returns a status line listing everyone currently requesting
+// changes on a PR, or "" if nobody is.
+func (h *WebhookHandler) changesRequestedStatus(ctx context.Context, teamID, repoSlug string, prID int) string {
+	names, err := h.repoStore.GetChangeRequests(ctx, repoSlug, prID)
 	if err != nil {
-		h.log.Error("get PR messages", "repo", repoSlug, "pr", prID, "err", err)
-		return
+		h.log.Error("get change requests", "repo", repoSlug, "pr", prID, "err", err)
 	}
+	if len(names) == 0 {
+		return ""
+	}
+	return ":warning: Changes requested by " + h.resolveUsers(ctx, teamID, names)
+}
 
-	if len(msgs) == 0 {
-		channels, _ := h.repoStore.ChannelsForRepo(ctx, repoSlug)
-		for _, ch := range channels {
-			h.slack.PostMessage(ch, slacklib.MsgOptionBlocks(blocks...))
+// teamMessage renders a PR card (nil for none) and a thread reply ("" for none) for one
+// Slack team, so mentions resolve to that team's linked users.
+type teamMessage func(ctx context.Context, teamID string) (blocks []slacklib.Block, text string)
+
+// perTeam wraps render so it runs once per team, however many of the team's channels get it.
+func perTeam(render teamMessage) teamMessage {
+	type rendered struct {
+		blocks []slacklib.Block
+		text   string
+	}
+	cache := make(map[string]rendered)
+	return func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		r, ok := cache[teamID]
+		if !ok {
+			r.blocks, r.text = render(ctx, teamID)
+			cache[teamID] = r
 		}
+		return r.blocks, r.text
+	}
+}
+
+// updateAndReply updates the original Slack message and posts a thread reply.
+// Subscribed channels whose filter allows ev but that have no card for the PR yet
+// (because they filtered out earlier events, or subscribed later) get a fresh card instead.
+func (h *WebhookHandler) updateAndReply(repoSlug string, prID int, ev store.FilterEvent, render teamMessage) {
+	ctx := context.Background()
+	render = perTeam(render)
+	carded, ok := h.queueForPR(ctx, repoSlug, prID, ev, render)
+	if !ok {
 		return
 	}
 
-	for _, msg := range msgs {
-		if _, _, _, err := h.slack.UpdateMessage(msg.ChannelID, msg.MessageTS, slacklib.MsgOptionBlocks(blocks...)); err != nil {
-			h.log.Error("update PR message", "channel", msg.ChannelID, "err", err)
+	subs, err := h.repoStore.ChannelsForRepo(ctx, repoSlug)
+	if err != nil {
+		h.log.Error("look up channels for repo", "repo", repoSlug, "err", err)
+		return
+	}
+	for _, sub := range subs {
+		if carded[sub.ChannelID] || !sub.Filter.Allows(ev) {
+			continue
 		}
-		if _, _, err := h.slack.PostMessage(msg.ChannelID,
-			slacklib.MsgOptionTS(msg.MessageTS),
-			slacklib.MsgOptionText(replyText, false),
-		); err != nil {
-			h.log.Error("post thread reply", "channel", msg.ChannelID, "err", err)
+		blocks, _ := render(ctx, sub.TeamID)
+		if err := h.outbox.PostCard(ctx, sub.TeamID, sub.ChannelID, repoSlug, prID, blocks); err != nil {
+			h.log.Error("queue PR notification", "channel", sub.ChannelID, "err", err)
 		}
 	}
 }
 
-// threadReply posts text as a thread reply to the original PR message.
-func (h *WebhookHandler) threadReply(repoSlug string, prID int, text string) {
+// updateCard updates the original Slack message for a PR in every channel without posting a reply.
+func (h *WebhookHandler) updateCard(repoSlug string, prID int, card func(ctx context.Context, teamID string) []slacklib.Block) {
+	h.queueForPR(context.Background(), repoSlug, prID, store.FilterEvent{}, perTeam(func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		return card(ctx, teamID), ""
+	}))
+}
+
+// threadReplyExcept posts a thread reply under the original PR message in every channel
+// still subscribed whose filter allows ev, skipping the thread in skipChannel ("" skips none).
+func (h *WebhookHandler) threadReplyExcept(repoSlug string, prID int, ev store.FilterEvent, reply func(ctx context.Context, teamID string) string, skipChannel string) {
 	ctx := context.Background()
-	msgs, err := h.repoStore.GetPRMessages(ctx, repoSlug, prID)
+	render := perTeam(func(ctx context.Context, teamID string) ([]slacklib.Block, string) {
+		return nil, reply(ctx, teamID)
+	})
+	if skipChannel == "" {
+		h.queueForPR(ctx, repoSlug, prID, ev, render)
+		return
+	}
+	chans, err := h.repoStore.GetPRChannels(ctx, repoSlug, prID)
 	if err != nil {
-		h.log.Error("get PR messages", "repo", repoSlug, "pr", prID, "err", err)
+		h.log.Error("get PR channels", "repo", repoSlug, "pr", prID, "err", err)
 		return
 	}
-	for _, msg := range msgs {
-		if _, _, err := h.slack.PostMessage(msg.ChannelID,
-			slacklib.MsgOptionTS(msg.MessageTS),
-			slacklib.MsgOptionText(text, false),
-		); err != nil {
-			h.log.Error("post thread reply", "channel", msg.ChannelID, "err", err)
+	for _, ch := range chans {
+		if ch.ChannelID == skipChannel || !ch.Subscribed || !ch.Filter.Allows(ev) {
+			continue
+		}
+		_, text := render(ctx, ch.TeamID)
+		if err := h.outbox.Reply(ctx, ch.TeamID, ch.ChannelID, repoSlug, prID, text); err != nil {
+			h.log.Error("queue thread reply", "channel", ch.ChannelID, "err", err)
 		}
 	}
 }
 
+// queueForPR queues a card update and a thread reply, as rendered for each channel's team,
+// in every channel that has, or is about to get, the PR card. Cards are always kept
+// current; the reply only goes to channels still subscribed whose filter allows ev.
+// Returns the set of channels with a card, and false if they could not be looked up.
+func (h *WebhookHandler) queueForPR(ctx context.Context, repoSlug string, prID int, ev store.FilterEvent, render teamMessage) (map[string]bool, bool) {
+	chans, err := h.repoStore.GetPRChannels(ctx, repoSlug, prID)
+	if err != nil {
+		h.log.Error("get PR channels", "repo", repoSlug, "pr", prID, "err", err)
+		return nil, false // don't fall back to fresh cards on a lookup error
+	}
+	carded := make(map[string]bool, len(chans))
+	for _, ch := range chans {
+		carded[ch.ChannelID] = true
+		blocks, text := render(ctx, ch.TeamID)
+		if blocks != nil {
+			if err := h.outbox.UpdateCard(ctx, ch.TeamID, ch.ChannelID, repoSlug, prID, blocks); err != nil {
+				h.log.Error("queue PR card update", "channel", ch.ChannelID, "err", err)
+			}
+		}
+		if text != "" && ch.Subscribed && ch.Filter.Allows(ev) {
+			if err := h.outbox.Reply(ctx, ch.TeamID, ch.ChannelID, repoSlug, prID, text); err != nil {
+				h.log.Error("queue thread reply", "channel", ch.ChannelID, "err", err)
+			}
+		}
+	}
+	return carded, true
+}
+
 // buildPRBlocks builds the Slack Block Kit message for a PR card.
 //
 // Layout:
@@ -489,7 +864,7 @@ func (h *WebhookHandler) threadReply(repoSlug string, prID int, text string) {
 //	Row 1: Pull request (bold link) | Repo (link)
 //	Row 2: Build (emoji + link or "—") | Branch (source → dest)
 //	Row 3: Reviewers (mentions or "—") | Author (mention)
-//	[optional status context block]
+//	[optional status context block: status line, "Changes requested by …" line]
 func buildPRBlocks(card prCard) []slacklib.Block {
 	repoURL := "https://bitbucket.org/" + card.repoFullName
 
@@ -523,17 +898,95 @@ func buildPRBlocks(card prCard) []slacklib.Block {
 		slacklib.NewDividerBlock(),
 	}
 
-	if card.statusLine != "" {
+	var status []string
+	for _, line := range []string{card.statusLine, card.changesLine} {
+		if line != "" {
+			status = append(status, line)
+		}
+	}
+	if len(status) > 0 {
 		blocks = append(blocks,
 			slacklib.NewContextBlock("",
-				slacklib.NewTextBlockObject(slacklib.MarkdownType, card.statusLine, false, false),
+				slacklib.NewTextBlockObject(slacklib.MarkdownType, strings.Join(status, "\n"), false, false),
 			),
 		)
 	}
 
+	// Review buttons act as the clicking user; see slackbot.Handler.HandleInteraction.
+	value := slackbot.PRActionValue(card.repoFullName, card.prID)
+	var buttons []slacklib.BlockElement
+	if card.open {
+		approve := slacklib.NewButtonBlockElement(slackbot.ActionPRApprove, value,
+			slacklib.NewTextBlockObject(slacklib.PlainTextType, "Approve", false, false))
+		approve.Style = slacklib.StylePrimary
+		requestChanges := slacklib.NewButtonBlockElement(slackbot.ActionPRRequestChanges, value,
+			slacklib.NewTextBlockObject(slacklib.PlainTextType, "Request changes", false, false))
+		requestChanges.Style = slacklib.StyleDanger
+		buttons = append(buttons, approve, requestChanges)
+	}
+	openDiff := slacklib.NewButtonBlockElement(slackbot.ActionPROpenDiff, value,
+		slacklib.NewTextBlockObject(slacklib.PlainTextType, "Open diff", false, false))
+	openDiff.URL = card.prURL + "/diff"
+	blocks = append(blocks, slacklib.NewActionBlock("pr_actions", append(buttons, openDiff)...))
+
 	return blocks
 }
 
+// isOpenState reports whether a PR state may still be reviewed. Unknown states count as open.
+func isOpenState(state string) bool {
+	return state == "" || state == store.PROpen || state == store.PRUnknown
+}
+
+// prCommitRecord converts a PR webhook payload into the record persisted in pr_commits.
+func prCommitRecord(p bbEventPayload) store.PRCommitRecord {
+	reviewerNames := make([]string, len(p.PullRequest.Reviewers))
+	for i, r := range p.PullRequest.Reviewers {
+		reviewerNames[i] = r.DisplayName
+	}
+	return store.PRCommitRecord{
+		RepoSlug:      p.Repository.FullName,
+		PRID:          p.PullRequest.ID,
+		CommitHash:    p.PullRequest.Source.Commit.Hash,
+		Title:         p.PullRequest.Title,
+		URL:           p.PullRequest.Links.HTML.Href,
+		AuthorName:    p.PullRequest.Author.DisplayName,
+		ReviewerNames: reviewerNames,
+		SourceBranch:  p.PullRequest.Source.Branch.Name,
+		DestBranch:    p.PullRequest.Destination.Branch.Name,
+		State:         cmp.Or(p.PullRequest.State, store.PROpen),
+		CreatedAt:     cmp.Or(p.PullRequest.CreatedOn, time.Now()),
+		UpdatedAt:     cmp.Or(p.PullRequest.UpdatedOn, time.Now()),
+	}
+}
+
+// diffNames returns the names present in cur but not in prev (added) and vice versa (removed).
+func diffNames(prev, cur []string) (added, removed []string) {
+	seen := make(map[string]bool, len(prev))
+	for _, n := range prev {
+		seen[n] = true
+	}
+	for _, n := range cur {
+		if !seen[n] {
+			added = append(added, n)
+		}
+		delete(seen, n)
+	}
+	for _, n := range prev {
+		if seen[n] {
+			removed = append(removed, n)
+		}
+	}
+	return added, removed
+}
+
+// shortHash abbreviates a commit hash to the 7 characters Bitbucket shows in its UI.
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
 // verifySignature checks the X-Hub-Signature header against HMAC-SHA256(secret, body).
 func verifySignature(secret string, body []byte, signature string) bool {
 	if !strings.HasPrefix(signature, "sha256=") {
@@ -555,6 +1008,7 @@ type bbEventPayload struct {
 		FullName string `json:"full_name"`
 	} `json:"repository"`
 	Comment struct {
+		ID      int `json:"id"`
 		Content struct {
 			Raw string `json:"raw"`
 		} `json:"content"`
@@ -562,9 +1016,12 @@ type bbEventPayload struct {
 }
 
 type bbPullRequest struct {
-	ID     int    `json:"id"`
-	Title  string `json:"title"`
-	Source struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	State     string    `json:"state"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+	Source    struct {
 		Branch struct {
 			Name string `json:"name"`
 		} `json:"branch"`
@@ -577,9 +1034,7 @@ type bbPullRequest struct {
 			Name string `json:"name"`
 		} `json:"branch"`
 	} `json:"destination"`
-	Author struct {
-		DisplayName string `json:"display_name"`
-	} `json:"author"`
+	Author    bbAccount `json:"author"`
 	Reviewers []struct {
 		DisplayName string `json:"display_name"`
 	} `json:"reviewers"`
@@ -590,6 +1045,24 @@ type bbPullRequest struct {
 	} `json:"links"`
 }
 
+// bbAccount identifies a Bitbucket user in webhook payloads.
+type bbAccount struct {
+	DisplayName string `json:"display_name"`
+	Nickname    string `json:"nickname"`
+	UUID        string `json:"uuid"`
+}
+
+// isOwner reports whether a CODEOWNERS owner ("@nickname", "@{uuid}" or a display name)
+// names this account.
+func (a bbAccount) isOwner(owner string) bool {
+	handle, ok := strings.CutPrefix(owner, "@")
+	if !ok {
+		return strings.EqualFold(owner, a.DisplayName)
+	}
+	return a.Nickname != "" && strings.EqualFold(handle, a.Nickname) ||
+		a.UUID != "" && strings.EqualFold(strings.Trim(handle, "{}"), strings.Trim(a.UUID, "{}"))
+}
+
 // bbCommitStatusPayload covers repo:commit_status_created and repo:commit_status_updated events.
 type bbCommitStatusPayload struct {
 	Repository struct {
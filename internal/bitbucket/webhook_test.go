@@ -0,0 +1,159 @@
+package bitbucket
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sign returns the X-Hub-Signature Bitbucket sends for body under secret.
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// testSecrets are the webhook secrets configured for the tests: a repository
+// webhook in acme and workspace webhooks in globex and initech.
+var testSecrets = map[string]string{
+	"acme/web":  "repo-secret",
+	"globex/*":  "workspace-secret",
+	"initech/*": "other-workspace-secret",
+}
+
+// newTestWebhookHandler returns a handler that only knows testSecrets. Its store and
+// outbox are nil, so any side effect after the signature check panics.
+func newTestWebhookHandler(lookups *[]string) *WebhookHandler {
+	return &WebhookHandler{
+		webhookSecret: func(_ context.Context, slug string) (string, error) {
+			*lookups = append(*lookups, slug)
+			return testSecrets[slug], nil
+		},
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	const body = `{"repository":{"full_name":"acme/web"}}`
+	tests := []struct {
+		name      string
+		secret    string
+		body      string
+		signature string
+		want      bool
+	}{
+		{"valid", "repo-secret", body, sign("repo-secret", body), true},
+		{"wrong secret", "repo-secret", body, sign("guessed", body), false},
+		{"tampered body", "repo-secret", body + " ", sign("repo-secret", body), false},
+		{"missing header", "repo-secret", body, "", false},
+		{"missing prefix", "repo-secret", body, strings.TrimPrefix(sign("repo-secret", body), "sha256="), false},
+		{"sha1 prefix", "repo-secret", body, "sha1=" + strings.TrimPrefix(sign("repo-secret", body), "sha256="), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifySignature(tt.secret, []byte(tt.body), tt.signature); got != tt.want {
+				t.Errorf("verifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSecretsRepoAndWorkspace(t *testing.T) {
+	tests := []struct {
+		name     string
+		repoSlug string
+		signer   string
+		want     bool
+	}{
+		{"repo secret on repo webhook", "acme/web", "repo-secret", true},
+		{"repo secret on another repo", "acme/api", "repo-secret", false},
+		{"workspace secret", "globex/web", "workspace-secret", true},
+		{"another workspace's secret", "globex/web", "other-workspace-secret", false},
+		{"repo secret on workspace webhook", "globex/web", "repo-secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookups []string
+			h := newTestWebhookHandler(&lookups)
+			secrets, err := h.webhookSecrets(context.Background(), tt.repoSlug)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := `{"repository":{"full_name":"` + tt.repoSlug + `"}}`
+			signature := sign(tt.signer, body)
+			got := slices.ContainsFunc(secrets, func(s string) bool { return verifySignature(s, []byte(body), signature) })
+			if got != tt.want {
+				t.Errorf("signature accepted = %v, want %v (secrets %q)", got, tt.want, secrets)
+			}
+		})
+	}
+}
+
+func TestHandleRejectsForgedPayloads(t *testing.T) {
+	const (
+		prBody      = `{"actor":{"display_name":"Mallory"},"repository":{"full_name":"%s"},"pullrequest":{"id":7,"title":"Forged","state":"OPEN"}}`
+		commentBody = `{"actor":{"display_name":"Mallory"},"repository":{"full_name":"%s"},"pullrequest":{"id":7,"state":"OPEN"},"comment":{"id":1,"content":{"raw":"hi"}}}`
+		statusBody  = `{"repository":{"full_name":"%s"},"commit_status":{"state":"SUCCESSFUL","name":"ci","commit":{"hash":"abc"}}}`
+	)
+	tests := []struct {
+		name     string
+		event    string
+		body     string
+		repoSlug string
+		signer   string // "" sends no X-Hub-Signature
+	}{
+		{"PR created, unsigned", "pullrequest:created", prBody, "acme/web", ""},
+		{"PR updated, wrong secret", "pullrequest:updated", prBody, "acme/web", "guessed"},
+		{"PR merged, workspace secret of another workspace", "pullrequest:fulfilled", prBody, "globex/web", "other-workspace-secret"},
+		{"PR declined, repo secret on workspace webhook", "pullrequest:rejected", prBody, "globex/web", "repo-secret"},
+		{"PR approved, wrong secret", "pullrequest:approved", prBody, "acme/web", "workspace-secret"},
+		{"PR unapproved, unsigned", "pullrequest:unapproved", prBody, "globex/web", ""},
+		{"changes requested, wrong secret", "pullrequest:changes_request_created", prBody, "acme/web", "guessed"},
+		{"change request removed, unsigned", "pullrequest:changes_request_removed", prBody, "acme/web", ""},
+		{"comment created, unsigned", "pullrequest:comment_created", commentBody, "acme/web", ""},
+		{"comment created, wrong secret", "pullrequest:comment_created", commentBody, "globex/web", "guessed"},
+		{"commit status created, unsigned", "repo:commit_status_created", statusBody, "acme/web", ""},
+		{"commit status updated, wrong secret", "repo:commit_status_updated", statusBody, "globex/web", "repo-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookups []string
+			h := newTestWebhookHandler(&lookups)
+			app := fiber.New()
+			app.Post("/bitbucket/webhook", h.Handle)
+
+			body := fmt.Sprintf(tt.body, tt.repoSlug)
+			req := httptest.NewRequest(http.MethodPost, "/bitbucket/webhook", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Event-Key", tt.event)
+			req.Header.Set("X-Request-UUID", "forged-"+tt.event)
+			if tt.signer != "" {
+				// A valid HMAC, but under a secret this repository's webhooks do not use.
+				req.Header.Set("X-Hub-Signature", sign(tt.signer, body))
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != fiber.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+			}
+			workspace, _, _ := strings.Cut(tt.repoSlug, "/")
+			if want := []string{tt.repoSlug, workspace + "/*"}; !slices.Equal(lookups, want) {
+				t.Errorf("store lookups = %q, want only the secret lookups %q", lookups, want)
+			}
+		})
+	}
+}
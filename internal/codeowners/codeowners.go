@@ -0,0 +1,126 @@
+// Package codeowners parses CODEOWNERS files and resolves the owners of changed paths.
+//
+// Each non-blank, non-comment line is a path pattern followed by owners:
+//
+//	services/billing/   @alice @bob
+//	*.sql               "Dana Scully"
+//
+// Patterns follow the usual CODEOWNERS conventions: a leading '/' or an inner
+// '/' anchors the pattern to the repository root, otherwise it matches at any
+// depth; a pattern naming a directory covers everything beneath it. When
+// several lines match a file, the last one wins.
+//
+// Owners are returned as written: "@alice" names a Bitbucket account by
+// nickname ("@{uuid}" by UUID), anything else is a display name.
+package codeowners
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"bitbucket-slack-bot/internal/glob"
+)
+
+// Locations are the repository paths searched for a CODEOWNERS file, in order.
+var Locations = []string{".bitbucket/CODEOWNERS", "CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"}
+
+type rule struct {
+	pattern string // glob pattern relative to the repository root
+	owners  []string
+}
+
+// File is a parsed CODEOWNERS file.
+type File struct {
+	rules []rule
+}
+
+// Parse reads a CODEOWNERS file. Lines without owners and section headers
+// ("[Section]") are skipped; owners may be quoted to include spaces.
+func Parse(data []byte) *File {
+	f := &File{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		fields := splitFields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		owners := make([]string, 0, len(fields)-1)
+		for _, o := range fields[1:] {
+			if strings.HasPrefix(o, "#") {
+				break // trailing comment
+			}
+			owners = append(owners, o)
+		}
+		if len(owners) > 0 {
+			f.rules = append(f.rules, rule{pattern: toGlob(fields[0]), owners: owners})
+		}
+	}
+	return f
+}
+
+// Owners returns the distinct owners of paths, in the order they are first found.
+func (f *File) Owners(paths []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, p := range paths {
+		for _, o := range f.ownersOf(p) {
+			if !seen[o] {
+				seen[o] = true
+				out = append(out, o)
+			}
+		}
+	}
+	return out
+}
+
+// ownersOf returns the owners from the last rule matching path.
+func (f *File) ownersOf(path string) []string {
+	for i := len(f.rules) - 1; i >= 0; i-- {
+		r := f.rules[i]
+		if glob.Match(r.pattern, path) || glob.Match(r.pattern+"/**", path) {
+			return r.owners
+		}
+	}
+	return nil
+}
+
+// toGlob turns a CODEOWNERS pattern into a glob rooted at the repository root.
+func toGlob(pattern string) string {
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !anchored {
+		pattern = "**/" + pattern
+	}
+	return pattern
+}
+
+// splitFields splits a line on whitespace, keeping double-quoted runs together.
+func splitFields(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	inQuote := false
+	flush := func() {
+		if cur.Len() > 0 {
+			fields = append(fields, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case (r == ' ' || r == '\t') && !inQuote:
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return fields
+}
@@ -0,0 +1,57 @@
+// Package glob matches slash-separated names such as branch names and file
+// paths against shell-style patterns.
+//
+// A single star matches any run of characters except '/', a double star
+// matches across '/' as well ("src/**/main.go" also matches "src/main.go"),
+// and '?' matches one character other than '/'. Every other character
+// matches itself.
+package glob
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Match reports whether name matches pattern in full.
+func Match(pattern, name string) bool {
+	return compile(pattern).MatchString(name)
+}
+
+// MatchAny reports whether name matches at least one of patterns.
+func MatchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if Match(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// compile translates pattern into an anchored regular expression.
+func compile(pattern string) *regexp.Regexp {
+	runes := []rune(pattern)
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
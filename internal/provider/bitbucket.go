@@ -1,10 +1,15 @@
 package provider
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strconv"
 	"time"
 )
 
@@ -14,66 +19,284 @@ type bitbucketClient struct {
 	baseURL    string
 	workspace  string
 	authHeader string
+	pageLen    int
 	httpClient *http.Client
 }
 
+// Option configures a Bitbucket client.
+type Option func(*bitbucketClient)
+
+// WithPageSize sets how many items list endpoints return per page (Bitbucket's pagelen).
+// Values below 1 keep the default.
+func WithPageSize(n int) Option {
+	return func(c *bitbucketClient) {
+		if n > 0 {
+			c.pageLen = n
+		}
+	}
+}
+
 // NewOAuth creates a Bitbucket client authenticated with an OAuth2 access token.
-func NewOAuth(workspace, accessToken string) Provider {
-	return &bitbucketClient{
+func NewOAuth(workspace, accessToken string, opts ...Option) Provider {
+	c := &bitbucketClient{
 		baseURL:    bitbucketDefaultBaseURL,
 		workspace:  workspace,
 		authHeader: "Bearer " + accessToken,
+		pageLen:    defaultPageLen,
 		httpClient: &http.Client{Timeout: 15 * time.Second},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *bitbucketClient) Workspace() string {
+	return c.workspace
 }
 
-func (c *bitbucketClient) ListOpenPRs(repoSlug string) ([]PullRequest, error) {
+func (c *bitbucketClient) ListOpenPRs(ctx context.Context, repoSlug string) ([]PullRequest, error) {
 	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests?state=OPEN", c.baseURL, c.workspace, repoSlug)
 
-	var raw struct {
-		Values []bbPR `json:"values"`
-	}
-	if err := c.get(url, &raw); err != nil {
+	prs, err := collect(paginate[bbPR](ctx, c, url), bbPR.toPR)
+	if err != nil {
 		return nil, fmt.Errorf("list PRs: %w", err)
 	}
-
-	prs := make([]PullRequest, len(raw.Values))
-	for i, r := range raw.Values {
-		prs[i] = r.toPR()
-	}
 	return prs, nil
 }
 
-func (c *bitbucketClient) GetPR(repoSlug string, prID int) (*PullRequest, error) {
+func (c *bitbucketClient) GetPR(ctx context.Context, repoSlug string, prID int) (*PullRequest, error) {
 	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d", c.baseURL, c.workspace, repoSlug, prID)
 
 	var raw bbPR
-	if err := c.get(url, &raw); err != nil {
+	if err := c.get(ctx, url, &raw); err != nil {
 		return nil, fmt.Errorf("get PR %d: %w", prID, err)
 	}
 	pr := raw.toPR()
 	return &pr, nil
 }
 
-func (c *bitbucketClient) ListRepos() ([]Repository, error) {
-	url := fmt.Sprintf("%s/repositories/%s", c.baseURL, c.workspace)
+func (c *bitbucketClient) ListChangedFiles(ctx context.Context, repoSlug string, prID int) ([]string, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/diffstat", c.baseURL, c.workspace, repoSlug, prID)
 
-	var raw struct {
-		Values []bbRepo `json:"values"`
+	var paths []string
+	for entry, err := range paginate[bbDiffStat](ctx, c, url) {
+		if err != nil {
+			return nil, fmt.Errorf("list changed files for PR %d: %w", prID, err)
+		}
+		// Renames and deletions touch the old path too.
+		if entry.Old != nil && (entry.New == nil || entry.Old.Path != entry.New.Path) {
+			paths = append(paths, entry.Old.Path)
+		}
+		if entry.New != nil {
+			paths = append(paths, entry.New.Path)
+		}
 	}
-	if err := c.get(url, &raw); err != nil {
-		return nil, fmt.Errorf("list repos: %w", err)
+	return paths, nil
+}
+
+func (c *bitbucketClient) Approve(ctx context.Context, repoSlug string, prID int) error {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/approve", c.baseURL, c.workspace, repoSlug, prID)
+	if err := c.send(ctx, http.MethodPost, url, nil, nil); err != nil {
+		return fmt.Errorf("approve PR %d: %w", prID, err)
+	}
+	return nil
+}
+
+func (c *bitbucketClient) RequestChanges(ctx context.Context, repoSlug string, prID int) error {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/request-changes", c.baseURL, c.workspace, repoSlug, prID)
+	if err := c.send(ctx, http.MethodPost, url, nil, nil); err != nil {
+		return fmt.Errorf("request changes on PR %d: %w", prID, err)
+	}
+	return nil
+}
+
+func (c *bitbucketClient) CreateComment(ctx context.Context, repoSlug string, prID int, markdown string) (int, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/comments", c.baseURL, c.workspace, repoSlug, prID)
+	var in bbComment
+	in.Content.Raw = markdown
+	var out bbComment
+	if err := c.send(ctx, http.MethodPost, url, in, &out); err != nil {
+		return 0, fmt.Errorf("comment on PR %d: %w", prID, err)
+	}
+	return out.ID, nil
+}
+
+func (c *bitbucketClient) GetFileContent(ctx context.Context, repoSlug, ref, path string) ([]byte, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/src/%s/%s", c.baseURL, c.workspace, repoSlug, neturl.PathEscape(ref), path)
+
+	body, err := c.getRaw(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("get %s at %s: %w", path, ref, err)
+	}
+	return body, nil
+}
+
+func (c *bitbucketClient) GetWorkspace(ctx context.Context) (*Workspace, error) {
+	url := fmt.Sprintf("%s/workspaces/%s", c.baseURL, c.workspace)
+
+	var raw bbWorkspace
+	if err := c.get(ctx, url, &raw); err != nil {
+		return nil, fmt.Errorf("get workspace %s: %w", c.workspace, err)
 	}
+	return &Workspace{Slug: raw.Slug, Name: raw.Name}, nil
+}
+
+func (c *bitbucketClient) GetRepo(ctx context.Context, repoSlug string) (*Repository, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s", c.baseURL, c.workspace, repoSlug)
 
-	repos := make([]Repository, len(raw.Values))
-	for i, r := range raw.Values {
-		repos[i] = r.toRepo()
+	var raw bbRepo
+	if err := c.get(ctx, url, &raw); err != nil {
+		return nil, fmt.Errorf("get repo %s: %w", repoSlug, err)
+	}
+	repo := raw.toRepo()
+	return &repo, nil
+}
+
+func (c *bitbucketClient) ListRepos(ctx context.Context) ([]Repository, error) {
+	url := fmt.Sprintf("%s/repositories/%s", c.baseURL, c.workspace)
+
+	repos, err := collect(paginate[bbRepo](ctx, c, url), bbRepo.toRepo)
+	if err != nil {
+		return nil, fmt.Errorf("list repos: %w", err)
 	}
 	return repos, nil
 }
 
+func (c *bitbucketClient) ListWebhooks(ctx context.Context, repoSlug string) ([]Webhook, error) {
+	return c.listHooks(ctx, c.repoHooksURL(repoSlug))
+}
+
+func (c *bitbucketClient) CreateWebhook(ctx context.Context, repoSlug string, hook Webhook, secret string) (*Webhook, error) {
+	return c.createHook(ctx, c.repoHooksURL(repoSlug), hook, secret)
+}
+
+func (c *bitbucketClient) UpdateWebhook(ctx context.Context, repoSlug string, hook Webhook, secret string) (*Webhook, error) {
+	return c.updateHook(ctx, c.repoHooksURL(repoSlug), hook, secret)
+}
+
+func (c *bitbucketClient) DeleteWebhook(ctx context.Context, repoSlug, uuid string) error {
+	return c.deleteHook(ctx, c.repoHooksURL(repoSlug), uuid)
+}
+
+func (c *bitbucketClient) ListWorkspaceWebhooks(ctx context.Context) ([]Webhook, error) {
+	return c.listHooks(ctx, c.workspaceHooksURL())
+}
+
+func (c *bitbucketClient) CreateWorkspaceWebhook(ctx context.Context, hook Webhook, secret string) (*Webhook, error) {
+	return c.createHook(ctx, c.workspaceHooksURL(), hook, secret)
+}
+
+func (c *bitbucketClient) UpdateWorkspaceWebhook(ctx context.Context, hook Webhook, secret string) (*Webhook, error) {
+	return c.updateHook(ctx, c.workspaceHooksURL(), hook, secret)
+}
+
+func (c *bitbucketClient) DeleteWorkspaceWebhook(ctx context.Context, uuid string) error {
+	return c.deleteHook(ctx, c.workspaceHooksURL(), uuid)
+}
+
+func (c *bitbucketClient) repoHooksURL(repoSlug string) string {
+	return fmt.Sprintf("%s/repositories/%s/%s/hooks", c.baseURL, c.workspace, repoSlug)
+}
+
+func (c *bitbucketClient) workspaceHooksURL() string {
+	return fmt.Sprintf("%s/workspaces/%s/hooks", c.baseURL, c.workspace)
+}
+
+// listHooks, createHook, updateHook and deleteHook work on either hooks collection;
+// repository and workspace webhooks share one API shape.
+
+func (c *bitbucketClient) listHooks(ctx context.Context, hooksURL string) ([]Webhook, error) {
+	hooks, err := collect(paginate[bbHook](ctx, c, hooksURL), bbHook.toWebhook)
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+func (c *bitbucketClient) createHook(ctx context.Context, hooksURL string, hook Webhook, secret string) (*Webhook, error) {
+	var raw bbHook
+	if err := c.send(ctx, http.MethodPost, hooksURL, newBBHookRequest(hook, secret), &raw); err != nil {
+		return nil, fmt.Errorf("create webhook: %w", err)
+	}
+	created := raw.toWebhook()
+	return &created, nil
+}
+
+func (c *bitbucketClient) updateHook(ctx context.Context, hooksURL string, hook Webhook, secret string) (*Webhook, error) {
+	var raw bbHook
+	if err := c.send(ctx, http.MethodPut, hooksURL+"/"+hook.UUID, newBBHookRequest(hook, secret), &raw); err != nil {
+		return nil, fmt.Errorf("update webhook %s: %w", hook.UUID, err)
+	}
+	updated := raw.toWebhook()
+	return &updated, nil
+}
+
+func (c *bitbucketClient) deleteHook(ctx context.Context, hooksURL, uuid string) error {
+	if err := c.send(ctx, http.MethodDelete, hooksURL+"/"+uuid, nil, nil); err != nil {
+		return fmt.Errorf("delete webhook %s: %w", uuid, err)
+	}
+	return nil
+}
+
 // --- Bitbucket API response shapes ---
 
+type bbDiffStat struct {
+	Old *struct {
+		Path string `json:"path"`
+	} `json:"old"`
+	New *struct {
+		Path string `json:"path"`
+	} `json:"new"`
+}
+
+type bbHook struct {
+	UUID        string   `json:"uuid"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Active      bool     `json:"active"`
+	Events      []string `json:"events"`
+}
+
+func (r bbHook) toWebhook() Webhook {
+	return Webhook{
+		UUID:        r.UUID,
+		URL:         r.URL,
+		Description: r.Description,
+		Active:      r.Active,
+		Events:      r.Events,
+	}
+}
+
+// bbComment is a PR comment as sent to and returned by Bitbucket; ID is only set
+// in responses.
+type bbComment struct {
+	ID      int `json:"id,omitempty"`
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+}
+
+// bbHookRequest is the body of a webhook create or update; Bitbucket never
+// echoes the secret back, so it only appears on the request side.
+type bbHookRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Active      bool     `json:"active"`
+	Events      []string `json:"events"`
+	Secret      string   `json:"secret,omitempty"`
+}
+
+func newBBHookRequest(hook Webhook, secret string) bbHookRequest {
+	return bbHookRequest{
+		URL:         hook.URL,
+		Description: hook.Description,
+		Active:      hook.Active,
+		Events:      hook.Events,
+		Secret:      secret,
+	}
+}
+
 type bbPR struct {
 	ID          int    `json:"id"`
 	Title       string `json:"title"`
@@ -82,6 +305,9 @@ type bbPR struct {
 	Author      struct {
 		DisplayName string `json:"display_name"`
 	} `json:"author"`
+	Reviewers []struct {
+		DisplayName string `json:"display_name"`
+	} `json:"reviewers"`
 	Source struct {
 		Branch struct {
 			Name string `json:"name"`
@@ -101,12 +327,17 @@ type bbPR struct {
 }
 
 func (r bbPR) toPR() PullRequest {
+	reviewers := make([]string, len(r.Reviewers))
+	for i, rv := range r.Reviewers {
+		reviewers[i] = rv.DisplayName
+	}
 	return PullRequest{
 		ID:           r.ID,
 		Title:        r.Title,
 		Description:  r.Description,
 		State:        r.State,
 		Author:       r.Author.DisplayName,
+		Reviewers:    reviewers,
 		SourceBranch: r.Source.Branch.Name,
 		TargetBranch: r.Destination.Branch.Name,
 		URL:          r.Links.HTML.Href,
@@ -114,6 +345,11 @@ func (r bbPR) toPR() PullRequest {
 	}
 }
 
+type bbWorkspace struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
 type bbRepo struct {
 	Slug        string `json:"slug"`
 	FullName    string `json:"full_name"`
@@ -136,28 +372,142 @@ func (r bbRepo) toRepo() Repository {
 	}
 }
 
-func (c *bitbucketClient) get(url string, out any) error {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+const (
+	// maxGetAttempts bounds how many times an idempotent GET is tried.
+	maxGetAttempts = 4
+	// maxRetryWait is the longest the client sleeps before a retry; a longer
+	// rate-limit wait is returned to the caller as a *RateLimitError instead.
+	maxRetryWait = 10 * time.Second
+)
+
+// get performs a GET and decodes the JSON response into out.
+func (c *bitbucketClient) get(ctx context.Context, url string, out any) error {
+	body, err := c.getRaw(ctx, url)
 	if err != nil {
 		return err
 	}
+	return json.Unmarshal(body, out)
+}
+
+// getRaw performs a GET and returns the response body. 429 and 5xx
+// responses are retried with exponential backoff, honouring Retry-After.
+func (c *bitbucketClient) getRaw(ctx context.Context, url string) ([]byte, error) {
+	var lastErr error
+	for attempt := range maxGetAttempts {
+		if attempt > 0 {
+			wait := retryWait(lastErr, attempt)
+			if wait > maxRetryWait {
+				return nil, lastErr
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		body, err := c.do(ctx, http.MethodGet, url, nil)
+		if err == nil {
+			return body, nil
+		}
+		if !retryable(err) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// send performs a single non-idempotent request with an optional JSON body and
+// decodes the response into out when it is non-nil. It is never retried.
+func (c *bitbucketClient) send(ctx context.Context, method, url string, in, out any) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	body, err := c.do(ctx, method, url, payload)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// do sends a single request and returns the body of a 2xx response, or a typed error.
+func (c *bitbucketClient) do(ctx context.Context, method, url string, payload []byte) ([]byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Authorization", c.authHeader)
 	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &RateLimitError{ResetAt: rateLimitReset(resp.Header)}
+	}
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
+	return body, nil
+}
 
-	return json.Unmarshal(body, out)
+// retryable reports whether a failed GET is worth repeating.
+func retryable(err error) bool {
+	var rl *RateLimitError
+	if errors.As(err, &rl) {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode >= 500
+}
+
+// retryWait returns how long to sleep before retry number attempt (1-based).
+func retryWait(err error, attempt int) time.Duration {
+	var rl *RateLimitError
+	if errors.As(err, &rl) && !rl.ResetAt.IsZero() {
+		return time.Until(rl.ResetAt)
+	}
+	return 500 * time.Millisecond << (attempt - 1)
+}
+
+// rateLimitReset reads when a 429 response says requests will be accepted again,
+// from Retry-After (seconds or HTTP date) or X-RateLimit-Reset (Unix seconds).
+func rateLimitReset(h http.Header) time.Time {
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Now().Add(time.Duration(secs) * time.Second)
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return t
+		}
+	}
+	if v := h.Get("X-RateLimit-Reset"); v != "" {
+		if unix, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(unix, 0)
+		}
+	}
+	return time.Time{}
 }
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetWorkspace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Bitbucket resolves workspace slugs case-insensitively and answers with the canonical one.
+		switch r.URL.Path {
+		case "/workspaces/Acme", "/workspaces/acme":
+			w.Write([]byte(`{"slug":"acme","name":"Acme Corp"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name      string
+		workspace string
+		want      string
+		wantErr   error
+	}{
+		{"canonical spelling", "acme", "acme", nil},
+		{"typed with capitals", "Acme", "acme", nil},
+		{"unknown workspace", "globex", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewOAuth(tt.workspace, "token").(*bitbucketClient)
+			c.baseURL = srv.URL
+
+			ws, err := c.GetWorkspace(context.Background())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ws.Slug != tt.want {
+				t.Errorf("Slug = %q, want %q", ws.Slug, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Sentinel errors for API responses callers commonly need to tell apart.
+// Match them with errors.Is; the concrete error is an *APIError.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+)
+
+// APIError is a non-2xx API response other than a rate limit.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap maps well-known status codes to the sentinel errors above.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RateLimitError is returned when the API keeps answering 429 after retries,
+// or asks the client to wait longer than it is willing to block.
+type RateLimitError struct {
+	// ResetAt is when the API said requests will be accepted again (zero if unknown).
+	ResetAt time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if e.ResetAt.IsZero() {
+		return "API rate limit exceeded"
+	}
+	return fmt.Sprintf("API rate limit exceeded, resets at %s", e.ResetAt.Format(time.RFC3339))
+}
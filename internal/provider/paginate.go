@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+// defaultPageLen is the page size requested from list endpoints unless the client
+// was built with WithPageSize; 50 is the largest value every Bitbucket list
+// endpoint accepts.
+const defaultPageLen = 50
+
+// page is the envelope Bitbucket wraps around every paginated list response.
+type page[T any] struct {
+	Values []T    `json:"values"`
+	Next   string `json:"next"`
+}
+
+// paginate iterates over every value of a paginated Bitbucket list endpoint,
+// requesting the client's page size and following "next" links until the last page. Iteration stops with ctx.Err()
+// if ctx is cancelled between pages, or with the first request error.
+func paginate[T any](ctx context.Context, c *bitbucketClient, rawURL string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		next, err := withPageLen(rawURL, c.pageLen)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		for next != "" {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			var p page[T]
+			if err := c.get(ctx, next, &p); err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, v := range p.Values {
+				if !yield(v, nil) {
+					return
+				}
+			}
+			next = p.Next
+		}
+	}
+}
+
+// collect drains a paginated iterator into a slice, converting each value with conv.
+func collect[T, R any](seq iter.Seq2[T, error], conv func(T) R) ([]R, error) {
+	var out []R
+	for v, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, conv(v))
+	}
+	return out, nil
+}
+
+// withPageLen sets the pagelen query parameter on rawURL.
+func withPageLen(rawURL string, pageLen int) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parse url: %w", err)
+	}
+	q := u.Query()
+	q.Set("pagelen", strconv.Itoa(pageLen))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// newPagedServer serves total open PRs for acme/web, pageLen at a time as requested
+// by the pagelen parameter, and records the pagelen of every request.
+func newPagedServer(t *testing.T, total int, pageLens *[]string) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repositories/acme/web/pullrequests" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		*pageLens = append(*pageLens, q.Get("pagelen"))
+		size, err := strconv.Atoi(q.Get("pagelen"))
+		if err != nil || size < 1 {
+			http.Error(w, "bad pagelen", http.StatusBadRequest)
+			return
+		}
+		pageNum := max(1, atoiOr(q.Get("page"), 1))
+
+		var body struct {
+			Values []map[string]any `json:"values"`
+			Next   string           `json:"next,omitempty"`
+		}
+		for id := (pageNum-1)*size + 1; id <= min(pageNum*size, total); id++ {
+			body.Values = append(body.Values, map[string]any{"id": id, "title": fmt.Sprintf("PR %d", id)})
+		}
+		if pageNum*size < total {
+			// Bitbucket's next links carry the original query, pagelen included.
+			body.Next = fmt.Sprintf("%s%s?state=OPEN&pagelen=%d&page=%d", srv.URL, r.URL.Path, size, pageNum+1)
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func atoiOr(s string, def int) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return def
+}
+
+func TestListOpenPRsPaginates(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		total        int
+		wantPageLens []string
+	}{
+		{"default page size, one page", nil, 3, []string{"50"}},
+		{"default page size, several pages", nil, 120, []string{"50", "50", "50"}},
+		{"custom page size", []Option{WithPageSize(2)}, 5, []string{"2", "2", "2"}},
+		{"custom page size, exact multiple", []Option{WithPageSize(2)}, 4, []string{"2", "2"}},
+		{"non-positive page size keeps default", []Option{WithPageSize(0)}, 1, []string{"50"}},
+		{"no PRs", []Option{WithPageSize(10)}, 0, []string{"10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pageLens []string
+			srv := newPagedServer(t, tt.total, &pageLens)
+			c := NewOAuth("acme", "token", tt.opts...).(*bitbucketClient)
+			c.baseURL = srv.URL
+
+			prs, err := c.ListOpenPRs(context.Background(), "web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prs) != tt.total {
+				t.Fatalf("got %d PRs, want %d", len(prs), tt.total)
+			}
+			for i, pr := range prs {
+				if pr.ID != i+1 {
+					t.Errorf("prs[%d].ID = %d, want %d", i, pr.ID, i+1)
+				}
+			}
+			if !slices.Equal(pageLens, tt.wantPageLens) {
+				t.Errorf("pagelen per request = %q, want %q", pageLens, tt.wantPageLens)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package provider
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // PullRequest is a provider-agnostic representation of a pull request.
 type PullRequest struct {
@@ -9,6 +12,7 @@ type PullRequest struct {
 	Description  string
 	State        string
 	Author       string
+	Reviewers    []string
 	SourceBranch string
 	TargetBranch string
 	URL          string
@@ -24,9 +28,71 @@ type Repository struct {
 	URL         string
 }
 
+// Workspace is a provider-agnostic representation of a workspace (repository owner).
+type Workspace struct {
+	// Slug is the workspace's canonical, lower-case identifier, as used in repository full names.
+	Slug string
+	Name string
+}
+
+// Webhook is a repository webhook registered with the provider.
+type Webhook struct {
+	UUID        string
+	URL         string
+	Description string
+	Active      bool
+	Events      []string
+}
+
+// WebhookEvents are the Bitbucket event keys the bot handles. They are both
+// the triggers requested when registering a webhook and the events the
+// webhook endpoint accepts.
+var WebhookEvents = []string{
+	"pullrequest:created",
+	"pullrequest:updated",
+	"pullrequest:fulfilled",
+	"pullrequest:rejected",
+	"pullrequest:approved",
+	"pullrequest:unapproved",
+	"pullrequest:changes_request_created",
+	"pullrequest:changes_request_removed",
+	"pullrequest:comment_created",
+	"repo:commit_status_created",
+	"repo:commit_status_updated",
+}
+
 // Provider is the interface every git hosting backend must implement.
+// Methods return errors matching ErrUnauthorized, ErrForbidden or ErrNotFound
+// (via errors.Is), or a *RateLimitError, where applicable.
 type Provider interface {
-	ListOpenPRs(repo string) ([]PullRequest, error)
-	GetPR(repo string, id int) (*PullRequest, error)
-	ListRepos() ([]Repository, error)
+	// Workspace returns the workspace (owner) the provider is authenticated for.
+	Workspace() string
+	// GetWorkspace looks up the authenticated workspace, returning its canonical slug.
+	GetWorkspace(ctx context.Context) (*Workspace, error)
+	ListOpenPRs(ctx context.Context, repo string) ([]PullRequest, error)
+	GetPR(ctx context.Context, repo string, id int) (*PullRequest, error)
+	// ListChangedFiles returns the paths a PR touches, including the old path of renamed files.
+	ListChangedFiles(ctx context.Context, repo string, id int) ([]string, error)
+	// GetFileContent returns the raw content of path at ref (branch, tag or commit).
+	GetFileContent(ctx context.Context, repo, ref, path string) ([]byte, error)
+	// Approve and RequestChanges review a PR as the authenticated user.
+	Approve(ctx context.Context, repo string, id int) error
+	RequestChanges(ctx context.Context, repo string, id int) error
+	// CreateComment posts a Markdown comment on a PR as the authenticated user and returns its ID.
+	CreateComment(ctx context.Context, repo string, id int, markdown string) (int, error)
+	GetRepo(ctx context.Context, repo string) (*Repository, error)
+	ListRepos(ctx context.Context) ([]Repository, error)
+
+	ListWebhooks(ctx context.Context, repo string) ([]Webhook, error)
+	// CreateWebhook and UpdateWebhook set the webhook's signing secret as well;
+	// an empty secret leaves it unchanged on update.
+	CreateWebhook(ctx context.Context, repo string, hook Webhook, secret string) (*Webhook, error)
+	UpdateWebhook(ctx context.Context, repo string, hook Webhook, secret string) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, repo, uuid string) error
+
+	// Workspace webhooks fire for every repository in the workspace, including ones created later.
+	ListWorkspaceWebhooks(ctx context.Context) ([]Webhook, error)
+	CreateWorkspaceWebhook(ctx context.Context, hook Webhook, secret string) (*Webhook, error)
+	UpdateWorkspaceWebhook(ctx context.Context, hook Webhook, secret string) (*Webhook, error)
+	DeleteWorkspaceWebhook(ctx context.Context, uuid string) error
 }
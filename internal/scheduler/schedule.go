@@ -0,0 +1,103 @@
+// Package scheduler runs periodic background jobs and computes weekly
+// wall-clock schedules such as "weekdays at 09:30 Europe/Berlin".
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embedded zone database: the runtime image (alpine) ships without one.
+	_ "time/tzdata"
+)
+
+// Schedule fires at a fixed local time of day on a set of weekdays.
+type Schedule struct {
+	Weekdays []time.Weekday
+	AtMinute int // minutes after local midnight
+	Location *time.Location
+}
+
+// Next returns the first firing time strictly after after. On days where the
+// local time does not exist (DST gaps) it fires at the normalised instant.
+func (s Schedule) Next(after time.Time) time.Time {
+	if len(s.Weekdays) == 0 {
+		return time.Time{}
+	}
+	local := after.In(s.Location)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		at := time.Date(day.Year(), day.Month(), day.Day(), s.AtMinute/60, s.AtMinute%60, 0, 0, s.Location)
+		if at.After(after) && slices.Contains(s.Weekdays, at.Weekday()) {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseDays parses "weekdays", "daily" or a comma-separated list of day names
+// such as "mon,wed,fri". Full names ("monday") are accepted too.
+func ParseDays(s string) ([]time.Weekday, error) {
+	switch strings.ToLower(s) {
+	case "weekdays":
+		return []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, nil
+	case "daily", "everyday":
+		return []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}, nil
+	}
+	var days []time.Weekday
+	for _, name := range strings.Split(strings.ToLower(s), ",") {
+		name = strings.TrimSpace(name)
+		if len(name) < 3 {
+			return nil, fmt.Errorf("unknown day %q", name)
+		}
+		d, ok := dayNames[name[:3]]
+		if !ok || !strings.HasPrefix(strings.ToLower(d.String()), name) {
+			return nil, fmt.Errorf("unknown day %q", name)
+		}
+		if !slices.Contains(days, d) {
+			days = append(days, d)
+		}
+	}
+	slices.Sort(days)
+	return days, nil
+}
+
+// ParseClock parses a 24-hour "HH:MM" time of day into minutes after midnight.
+func ParseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hour, herr := strconv.Atoi(h)
+	minute, merr := strconv.Atoi(m)
+	if !ok || herr != nil || merr != nil || len(m) != 2 || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// FormatDays is the inverse of ParseDays, e.g. "weekdays" or "Mon, Wed".
+func FormatDays(days []time.Weekday) string {
+	switch len(days) {
+	case 7:
+		return "daily"
+	case 5:
+		if !slices.Contains(days, time.Saturday) && !slices.Contains(days, time.Sunday) {
+			return "weekdays"
+		}
+	}
+	names := make([]string, len(days))
+	for i, d := range days {
+		names[i] = d.String()[:3]
+	}
+	return strings.Join(names, ", ")
+}
+
+// FormatClock formats minutes after midnight as "HH:MM".
+func FormatClock(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
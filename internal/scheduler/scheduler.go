@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic work. now is the tick time.
+type Job func(ctx context.Context, now time.Time)
+
+type entry struct {
+	name     string
+	interval time.Duration
+	fn       Job
+}
+
+// Scheduler runs registered jobs at fixed intervals until its context is cancelled.
+// Jobs claim their own work in the database, so every bot instance may run one.
+type Scheduler struct {
+	log  *slog.Logger
+	jobs []entry
+}
+
+// New returns an empty Scheduler.
+func New(log *slog.Logger) *Scheduler {
+	return &Scheduler{log: log}
+}
+
+// Every registers fn to run every interval, starting as soon as Run is called.
+// Must be called before Run.
+func (s *Scheduler) Every(interval time.Duration, name string, fn Job) {
+	s.jobs = append(s.jobs, entry{name: name, interval: interval, fn: fn})
+}
+
+// Run blocks, running each job on its own ticker, until ctx is cancelled.
+// A job never overlaps with itself: slow runs delay the next tick.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job entry) {
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+	for {
+		s.runOnce(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce runs job, logging rather than propagating a panic so one bad run
+// does not stop the job for good.
+func (s *Scheduler) runOnce(ctx context.Context, job entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Error("scheduled job panicked", "job", job.name, "panic", r)
+		}
+	}()
+	job.fn(ctx, time.Now())
+}
@@ -0,0 +1,165 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keySize is the AES-256 key length in bytes, used for both key-encryption keys
+// and per-record data keys.
+const keySize = 32
+
+// Keyring holds the named AES-256 key-encryption keys (KEKs) used for envelope
+// encryption. The first key is active and seals new data; the rest are kept so
+// data sealed under them can still be opened until it is re-encrypted.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// LoadKeyring builds a keyring from an inline spec and/or a key file.
+// Both use "id:base64key" entries, separated by commas or newlines; blank lines
+// and lines starting with "#" are ignored. Inline keys come first.
+// Returns nil (no error) when neither source is given.
+func LoadKeyring(spec, file string) (*Keyring, error) {
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read key file: %w", err)
+		}
+		spec = spec + "\n" + string(b)
+	}
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	k := &Keyring{keys: make(map[string][]byte)}
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("key entry %q: want id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %q: want %d bytes, got %d", id, keySize, len(key))
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("key %q listed twice", id)
+		}
+		if k.active == "" {
+			k.active = id
+		}
+		k.keys[id] = key
+	}
+	if k.active == "" {
+		return nil, errors.New("key spec contains no keys")
+	}
+	return k, nil
+}
+
+// ActiveID returns the ID of the key used to seal new data.
+func (k *Keyring) ActiveID() string {
+	return k.active
+}
+
+// Seal encrypts values under a freshly generated data key, which is itself
+// encrypted with the active KEK. It returns the KEK ID, the wrapped data key and
+// the ciphertexts, all base64-encoded and ready to store.
+func (k *Keyring) Seal(values ...string) (keyID, dataKey string, sealed []string, err error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", "", nil, err
+	}
+
+	wrapped, err := encrypt(k.keys[k.active], dek)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	sealed = make([]string, len(values))
+	for i, v := range values {
+		ct, err := encrypt(dek, []byte(v))
+		if err != nil {
+			return "", "", nil, err
+		}
+		sealed[i] = base64.StdEncoding.EncodeToString(ct)
+	}
+	return k.active, base64.StdEncoding.EncodeToString(wrapped), sealed, nil
+}
+
+// Open reverses Seal: it unwraps dataKey with the KEK named keyID and decrypts values.
+func (k *Keyring) Open(keyID, dataKey string, values ...string) ([]string, error) {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", keyID)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode data key: %w", err)
+	}
+	dek, err := decrypt(kek, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+
+	opened := make([]string, len(values))
+	for i, v := range values {
+		ct, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("decode ciphertext: %w", err)
+		}
+		pt, err := decrypt(dek, ct)
+		if err != nil {
+			return nil, err
+		}
+		opened[i] = string(pt)
+	}
+	return opened, nil
+}
+
+// encrypt seals plaintext with AES-GCM, prefixing the random nonce.
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decrypt opens a nonce-prefixed AES-GCM ciphertext produced by encrypt.
+func decrypt(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ct := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ct, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,69 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"bitbucket-slack-bot/internal/store"
+
+	"github.com/slack-go/slack"
+)
+
+// Clients resolves the Slack API client for a team. Teams that installed the app
+// through "Add to Slack" get a client built from their own bot token; everything
+// else falls back to the single --slack-bot-token client, if one is configured.
+type Clients struct {
+	repoStore *store.RepoStore
+	fallback  *slack.Client
+
+	mu    sync.Mutex
+	cache map[string]*slack.Client
+}
+
+// NewClients creates a registry. fallbackToken may be empty when the bot only
+// serves teams installed via OAuth.
+func NewClients(repoStore *store.RepoStore, fallbackToken string) *Clients {
+	c := &Clients{
+		repoStore: repoStore,
+		cache:     make(map[string]*slack.Client),
+	}
+	if fallbackToken != "" {
+		c.fallback = slack.New(fallbackToken)
+	}
+	return c
+}
+
+// For returns the client that posts on behalf of the bot in teamID.
+func (c *Clients) For(ctx context.Context, teamID string) (*slack.Client, error) {
+	c.mu.Lock()
+	client, ok := c.cache[teamID]
+	c.mu.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	if teamID != "" {
+		inst, err := c.repoStore.GetSlackInstallation(ctx, teamID)
+		if err != nil {
+			return nil, fmt.Errorf("look up slack installation: %w", err)
+		}
+		if inst != nil {
+			return c.Set(teamID, inst.BotToken), nil
+		}
+	}
+
+	if c.fallback == nil {
+		return nil, fmt.Errorf("slack app is not installed in team %q", teamID)
+	}
+	return c.fallback, nil
+}
+
+// Set replaces the cached client for teamID with one using botToken and returns it.
+func (c *Clients) Set(teamID, botToken string) *slack.Client {
+	client := slack.New(botToken)
+	c.mu.Lock()
+	c.cache[teamID] = client
+	c.mu.Unlock()
+	return client
+}
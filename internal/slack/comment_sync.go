@@ -0,0 +1,137 @@
+package slack
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// slackMarkup matches Slack's <…> message markup: mentions, channels, links and broadcasts.
+var slackMarkup = regexp.MustCompile(`<([^<>]+)>`)
+
+// handleThreadReply posts a reply in a PR card's thread to the PR as a Bitbucket comment,
+// as the replying user's linked account. Replies from users who have not run /login,
+// bot messages and edits are ignored. The comment_created webhook that follows is kept
+// out of this thread by the synced_comments row; see store.RepoStore.SyncedCommentChannel.
+func (h *Handler) handleThreadReply(teamID string, ev *slackevents.MessageEvent) {
+	if ev.ThreadTimeStamp == "" || ev.ThreadTimeStamp == ev.TimeStamp || ev.BotID != "" || ev.User == "" {
+		return
+	}
+	if ev.SubType != "" && ev.SubType != "thread_broadcast" {
+		return
+	}
+	if strings.TrimSpace(ev.Text) == "" {
+		return
+	}
+
+	ctx := context.Background()
+	repoSlug, prID, err := h.repoStore.FindPRByMessage(ctx, ev.Channel, ev.ThreadTimeStamp)
+	if err != nil {
+		h.log.Error("find PR by thread", "channel", ev.Channel, "ts", ev.ThreadTimeStamp, "err", err)
+		return
+	}
+	if repoSlug == "" {
+		return // not a PR card thread
+	}
+
+	workspace, name, _ := strings.Cut(repoSlug, "/")
+	git, err := h.userProviderFor(ctx, teamID, ev.User, workspace)
+	if err != nil {
+		h.log.Error("bitbucket user provider", "user", ev.User, "err", err)
+		h.ephemeralInThread(ctx, teamID, ev, ":x: Failed to reach Bitbucket, so your reply was not added to the pull request.")
+		return
+	}
+	if git == nil {
+		return
+	}
+
+	// Slack retries events it thinks were not delivered; the claim makes sure a reply is posted once.
+	// It also records the body, so the comment_created webhook recognises the comment even if it
+	// arrives before CreateComment has returned its ID.
+	body := h.commentMarkdown(ctx, teamID, ev)
+	claimed, err := h.repoStore.ClaimSyncedComment(ctx, ev.Channel, ev.TimeStamp, repoSlug, prID, body)
+	if err != nil {
+		h.log.Error("claim synced comment", "channel", ev.Channel, "ts", ev.TimeStamp, "err", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	commentID, err := git.CreateComment(ctx, name, prID, body)
+	if err != nil {
+		h.log.Error("post PR comment from slack", "repo", repoSlug, "pr", prID, "user", ev.User, "err", err)
+		if err := h.repoStore.DeleteSyncedComment(ctx, ev.Channel, ev.TimeStamp); err != nil {
+			h.log.Error("release synced comment", "channel", ev.Channel, "ts", ev.TimeStamp, "err", err)
+		}
+		h.ephemeralInThread(ctx, teamID, ev, ":x: Your reply was not added to the pull request"+userProviderErrorHint(err))
+		return
+	}
+	if err := h.repoStore.SetSyncedCommentID(ctx, ev.Channel, ev.TimeStamp, commentID); err != nil {
+		h.log.Error("save synced comment", "repo", repoSlug, "comment", commentID, "err", err)
+	}
+	h.log.Info("synced slack reply to PR", "repo", repoSlug, "pr", prID, "comment", commentID, "user", ev.User)
+}
+
+// commentMarkdown converts a Slack reply to Bitbucket Markdown and adds a line
+// linking back to the Slack message.
+func (h *Handler) commentMarkdown(ctx context.Context, teamID string, ev *slackevents.MessageEvent) string {
+	text := slackMarkup.ReplaceAllStringFunc(ev.Text, func(m string) string {
+		target, label, _ := strings.Cut(m[1:len(m)-1], "|")
+		switch {
+		case strings.HasPrefix(target, "@"):
+			return "@" + h.slackUserName(ctx, teamID, target[1:])
+		case strings.HasPrefix(target, "#"), strings.HasPrefix(target, "!"):
+			if label != "" {
+				return label
+			}
+			return strings.Replace(target, "!", "@", 1)
+		case label != "":
+			return fmt.Sprintf("[%s](%s)", label, target)
+		default:
+			return strings.TrimPrefix(target, "mailto:")
+		}
+	})
+	text = html.UnescapeString(text)
+
+	source := "Slack"
+	if client, err := h.clients.For(ctx, teamID); err == nil {
+		link, err := client.GetPermalinkContext(ctx, &slack.PermalinkParameters{Channel: ev.Channel, Ts: ev.TimeStamp})
+		if err == nil {
+			source = "[Slack](" + link + ")"
+		}
+	}
+	return text + "\n\n_Sent from " + source + "_"
+}
+
+// slackUserName returns the Bitbucket name of a linked Slack user, or their Slack name.
+func (h *Handler) slackUserName(ctx context.Context, teamID, userID string) string {
+	if name, err := h.repoStore.GetBitbucketUser(ctx, teamID, userID); err == nil && name != "" {
+		return name
+	}
+	if client, err := h.clients.For(ctx, teamID); err == nil {
+		if user, err := client.GetUserInfoContext(ctx, userID); err == nil {
+			return cmp.Or(user.Profile.DisplayName, user.RealName, user.Name)
+		}
+	}
+	return userID
+}
+
+// ephemeralInThread shows text only to the author of ev, in ev's thread.
+func (h *Handler) ephemeralInThread(ctx context.Context, teamID string, ev *slackevents.MessageEvent, text string) {
+	client, err := h.clients.For(ctx, teamID)
+	if err != nil {
+		h.log.Error("resolve slack client", "team", teamID, "err", err)
+		return
+	}
+	if _, err := client.PostEphemeralContext(ctx, ev.Channel, ev.User,
+		slack.MsgOptionText(text, false), slack.MsgOptionTS(ev.ThreadTimeStamp)); err != nil {
+		h.log.Error("post ephemeral", "channel", ev.Channel, "err", err)
+	}
+}
@@ -0,0 +1,351 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bitbucket-slack-bot/internal/scheduler"
+	"bitbucket-slack-bot/internal/store"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// defaultStaleDays is used when /repo digest set is given no stale=N.
+	defaultStaleDays = 3
+	// digestLease bounds how long one instance may hold a due digest before another retries it.
+	digestLease = 5 * time.Minute
+	// digestGroupLimit caps the PRs listed per group to keep the message within Slack's limits.
+	digestGroupLimit = 15
+	// digestSectionLimit is the most text put in one section block; Slack rejects
+	// section text over 3000 characters.
+	digestSectionLimit = 2900
+)
+
+const digestUsage = "Usage: `/repo digest set <weekdays|daily|mon,tue,…> <HH:MM> [tz=Area/City] [stale=N]`, `/repo digest show`, `/repo digest off`, `/repo digest now`"
+
+// handleRepoDigest implements /repo digest: configure, show, disable or preview the
+// channel's scheduled open-PR digest. Replies go to responseURL.
+func (h *Handler) handleRepoDigest(cmd slack.SlashCommand, args []string) {
+	reply := func(text string) {
+		h.postToResponseURL(cmd.ResponseURL, interactionReply{Text: text})
+	}
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		reply(digestUsage)
+		return
+	}
+	switch args[0] {
+	case "set":
+		d, err := h.parseDigestSchedule(ctx, cmd, args[1:])
+		if err != nil {
+			reply(":warning: " + err.Error() + "\n" + digestUsage)
+			return
+		}
+		if err := h.repoStore.SaveDigestSchedule(ctx, *d); err != nil {
+			h.log.Error("save digest schedule", "channel", cmd.ChannelID, "err", err)
+			reply(":x: Failed to save the digest schedule")
+			return
+		}
+		reply(":white_check_mark: " + describeDigest(d))
+	case "show":
+		d, err := h.repoStore.GetDigestSchedule(ctx, cmd.ChannelID)
+		if err != nil {
+			h.log.Error("get digest schedule", "channel", cmd.ChannelID, "err", err)
+			reply(":x: Failed to load the digest schedule")
+			return
+		}
+		if d == nil {
+			reply("No digest is scheduled for this channel. Set one with `/repo digest set weekdays 09:30`.")
+			return
+		}
+		reply(describeDigest(d))
+	case "off":
+		if err := h.repoStore.DeleteDigestSchedule(ctx, cmd.ChannelID); err != nil {
+			h.log.Error("delete digest schedule", "channel", cmd.ChannelID, "err", err)
+			reply(":x: Failed to turn off the digest")
+			return
+		}
+		reply(":white_check_mark: Digest turned off for this channel.")
+	case "now":
+		staleDays := defaultStaleDays
+		if d, err := h.repoStore.GetDigestSchedule(ctx, cmd.ChannelID); err == nil && d != nil {
+			staleDays = d.StaleDays
+		}
+		if err := h.postDigest(ctx, cmd.TeamID, cmd.ChannelID, staleDays, time.Now()); err != nil {
+			h.log.Error("post digest", "channel", cmd.ChannelID, "err", err)
+			reply(":x: Failed to post the digest")
+		}
+	default:
+		reply(digestUsage)
+	}
+}
+
+// parseDigestSchedule parses the arguments of /repo digest set. Without tz= the
+// invoking user's Slack time zone is used, falling back to UTC.
+func (h *Handler) parseDigestSchedule(ctx context.Context, cmd slack.SlashCommand, args []string) (*store.DigestSchedule, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("days and time are required")
+	}
+	days, err := scheduler.ParseDays(args[0])
+	if err != nil {
+		return nil, err
+	}
+	atMinute, err := scheduler.ParseClock(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	tz, staleDays := "", defaultStaleDays
+	for _, arg := range args[2:] {
+		key, value, _ := strings.Cut(arg, "=")
+		switch key {
+		case "tz":
+			tz = value
+		case "stale":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("stale must be a positive number of days")
+			}
+			staleDays = n
+		default:
+			return nil, fmt.Errorf("unknown option %q", arg)
+		}
+	}
+	if tz == "" {
+		tz = h.userTimezone(ctx, cmd.TeamID, cmd.UserID)
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", tz)
+	}
+
+	sched := scheduler.Schedule{Weekdays: days, AtMinute: atMinute, Location: loc}
+	return &store.DigestSchedule{
+		ChannelID: cmd.ChannelID,
+		TeamID:    cmd.TeamID,
+		Weekdays:  days,
+		AtMinute:  atMinute,
+		Timezone:  loc.String(),
+		StaleDays: staleDays,
+		NextRunAt: sched.Next(time.Now()),
+	}, nil
+}
+
+// userTimezone returns the Slack user's IANA time zone, or "UTC" if it cannot be read.
+func (h *Handler) userTimezone(ctx context.Context, teamID, userID string) string {
+	client, err := h.clients.For(ctx, teamID)
+	if err != nil {
+		return "UTC"
+	}
+	user, err := client.GetUserInfoContext(ctx, userID)
+	if err != nil || user.TZ == "" {
+		h.log.Warn("read user time zone", "user", userID, "err", err)
+		return "UTC"
+	}
+	return user.TZ
+}
+
+func describeDigest(d *store.DigestSchedule) string {
+	return fmt.Sprintf("Open-PR digest: %s at %s (%s), stale after %d day%s. Next: %s.",
+		scheduler.FormatDays(d.Weekdays), scheduler.FormatClock(d.AtMinute), d.Timezone,
+		d.StaleDays, plural(d.StaleDays), digestNextLabel(d))
+}
+
+func digestNextLabel(d *store.DigestSchedule) string {
+	loc, err := time.LoadLocation(d.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return d.NextRunAt.In(loc).Format("Mon Jan 2 15:04")
+}
+
+// RunDueDigests posts every digest that is due at now and schedules its next run.
+// It is registered with the scheduler and safe to run on several instances at once.
+func (h *Handler) RunDueDigests(ctx context.Context, now time.Time) {
+	due, err := h.repoStore.ClaimDueDigests(ctx, now, digestLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			h.log.Error("claim due digests", "err", err)
+		}
+		return
+	}
+
+	for _, d := range due {
+		loc, err := time.LoadLocation(d.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		next := scheduler.Schedule{Weekdays: d.Weekdays, AtMinute: d.AtMinute, Location: loc}.Next(now)
+
+		// A failed post is not retried: the next scheduled digest supersedes it.
+		var sentAt time.Time
+		if err := h.postDigest(ctx, d.TeamID, d.ChannelID, d.StaleDays, now); err != nil {
+			h.log.Error("post digest", "channel", d.ChannelID, "err", err)
+		} else {
+			sentAt = now
+		}
+		if err := h.repoStore.CompleteDigest(ctx, d.ChannelID, next, sentAt); err != nil {
+			h.log.Error("complete digest", "channel", d.ChannelID, "err", err)
+		}
+	}
+}
+
+// postDigest builds the open-PR digest for the channel's subscriptions and posts it.
+func (h *Handler) postDigest(ctx context.Context, teamID, channelID string, staleDays int, now time.Time) error {
+	subs, err := h.repoStore.SubscriptionsForChannel(ctx, channelID)
+	if err != nil {
+		return fmt.Errorf("list subscriptions: %w", err)
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	slugs := make([]string, len(subs))
+	for i, sub := range subs {
+		slugs[i] = sub.RepoSlug
+	}
+	prs, err := h.repoStore.ListOpenPRs(ctx, slugs)
+	if err != nil {
+		return fmt.Errorf("list open PRs: %w", err)
+	}
+
+	client, err := h.clients.For(ctx, teamID)
+	if err != nil {
+		return fmt.Errorf("resolve slack client: %w", err)
+	}
+	blocks := buildDigestBlocks(digestPRs(subs, prs), staleDays, now)
+	_, _, err = client.PostMessageContext(ctx, channelID,
+		slack.MsgOptionText("Open pull request digest", false),
+		slack.MsgOptionBlocks(blocks...),
+	)
+	return err
+}
+
+// digestPRs keeps the PRs that fall within at least one of the channel's
+// subscriptions, branch, author and path filters included.
+func digestPRs(subs []store.Subscription, prs []store.OpenPR) []store.OpenPR {
+	var out []store.OpenPR
+	for _, pr := range prs {
+		ev := store.FilterEvent{
+			TargetBranch: pr.DestBranch,
+			SourceBranch: pr.SourceBranch,
+			Author:       pr.AuthorName,
+			Paths:        pr.ChangedPaths,
+		}
+		workspace, _, _ := strings.Cut(pr.RepoSlug, "/")
+		for _, sub := range subs {
+			if (sub.RepoSlug == pr.RepoSlug || sub.RepoSlug == store.WorkspaceSlug(workspace)) && sub.Filter.MatchesPR(ev) {
+				out = append(out, pr)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// buildDigestBlocks groups PRs by what they are waiting on. Each PR appears once,
+// in the first group that applies: build failing, approved awaiting merge, stale,
+// needs review.
+func buildDigestBlocks(prs []store.OpenPR, staleDays int, now time.Time) []slack.Block {
+	groups := []struct {
+		title string
+		prs   []store.OpenPR
+	}{
+		{title: ":x: *Build failing*"},
+		{title: ":white_check_mark: *Approved, awaiting merge*"},
+		{title: fmt.Sprintf(":hourglass: *Stale (no activity for %d+ day%s)*", staleDays, plural(staleDays))},
+		{title: ":eyes: *Needs review*"},
+	}
+	staleBefore := now.Add(-time.Duration(staleDays) * 24 * time.Hour)
+	for _, pr := range prs {
+		var i int
+		switch {
+		case strings.EqualFold(pr.BuildState, "FAILED"):
+			i = 0
+		case len(pr.Approvals) > 0 && len(pr.ChangeRequests) == 0:
+			i = 1
+		case pr.UpdatedAt.Before(staleBefore):
+			i = 2
+		default:
+			i = 3
+		}
+		groups[i].prs = append(groups[i].prs, pr)
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("*Open pull requests (%d)*", len(prs)), false, false), nil, nil),
+	}
+	if len(prs) == 0 {
+		return append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "No open pull requests :tada:", false, false), nil, nil))
+	}
+	for _, g := range groups {
+		if len(g.prs) == 0 {
+			continue
+		}
+		lines := []string{fmt.Sprintf("%s (%d)", g.title, len(g.prs))}
+		for _, pr := range g.prs[:min(len(g.prs), digestGroupLimit)] {
+			lines = append(lines, formatDigestPR(pr, now))
+		}
+		if extra := len(g.prs) - digestGroupLimit; extra > 0 {
+			lines = append(lines, fmt.Sprintf("…and %d more", extra))
+		}
+		blocks = append(blocks, slack.NewDividerBlock())
+		for _, text := range joinLines(lines, digestSectionLimit) {
+			blocks = append(blocks, slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil))
+		}
+	}
+	return blocks
+}
+
+// joinLines joins lines with newlines into as few texts as possible of at most limit
+// characters each. A single line longer than limit is truncated.
+func joinLines(lines []string, limit int) []string {
+	var texts []string
+	var cur []string
+	n := 0 // characters in cur, including newlines
+	for _, line := range lines {
+		if r := []rune(line); len(r) > limit {
+			line = string(r[:limit-1]) + "…"
+		}
+		size := utf8.RuneCountInString(line)
+		if len(cur) > 0 && n+1+size > limit {
+			texts = append(texts, strings.Join(cur, "\n"))
+			cur, n = nil, 0
+		}
+		if len(cur) > 0 {
+			n++
+		}
+		cur = append(cur, line)
+		n += size
+	}
+	if len(cur) > 0 {
+		texts = append(texts, strings.Join(cur, "\n"))
+	}
+	return texts
+}
+
+// formatDigestPR renders one PR as a single mrkdwn line. Names are not mentions:
+// a recurring digest should not ping everyone it lists.
+func formatDigestPR(pr store.OpenPR, now time.Time) string {
+	title := pr.Title
+	if r := []rune(title); len(r) > 80 {
+		title = string(r[:79]) + "…"
+	}
+	line := fmt.Sprintf("• <%s|#%d %s> `%s` · %s · opened %s ago",
+		pr.URL, pr.PRID, title, pr.RepoSlug, pr.AuthorName, formatAge(now.Sub(pr.CreatedAt)))
+	if n := len(pr.Approvals); n > 0 {
+		line += fmt.Sprintf(" · %d approval%s", n, plural(n))
+	}
+	if len(pr.ChangeRequests) > 0 {
+		line += " · changes requested"
+	}
+	return line
+}
@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"bitbucket-slack-bot/internal/provider"
 	"bitbucket-slack-bot/internal/store"
@@ -20,57 +19,48 @@ import (
 
 // Handler processes Slack events and slash commands.
 type Handler struct {
-	client    *slack.Client
-	repoStore *store.RepoStore
-	oauthURL  func(teamID, channelID, userID, workspace string) string
-	loginURL  func(slackUserID, channelID string) string
-	publicURL string
-	log       *slog.Logger
+	clients         *Clients
+	repoStore       *store.RepoStore
+	oauthURL        func(teamID, channelID, userID, workspace string) (string, error)
+	loginURL        func(teamID, slackUserID, channelID string) (string, error)
+	providerFor     func(ctx context.Context, teamID string) (provider.Provider, error)
+	userProviderFor func(ctx context.Context, teamID, slackUserID, workspace string) (provider.Provider, error)
+	publicURL       string
+	log             *slog.Logger
 }
 
-func NewHandler(client *slack.Client, repoStore *store.RepoStore, oauthURL func(teamID, channelID, userID, workspace string) string, loginURL func(slackUserID, channelID string) string, publicURL string, log *slog.Logger) *Handler {
+// NewHandler creates the Slack handler. providerFor returns a Bitbucket client for a Slack
+// team's connected workspace (nil if it has none), refreshing its token as needed.
+// userProviderFor returns a Bitbucket client acting as the account a Slack user linked in
+// a team (nil if they have none); PR card buttons use it.
+func NewHandler(clients *Clients, repoStore *store.RepoStore, oauthURL func(teamID, channelID, userID, workspace string) (string, error), loginURL func(teamID, slackUserID, channelID string) (string, error), providerFor func(ctx context.Context, teamID string) (provider.Provider, error), userProviderFor func(ctx context.Context, teamID, slackUserID, workspace string) (provider.Provider, error), publicURL string, log *slog.Logger) *Handler {
 	return &Handler{
-		client:    client,
-		repoStore: repoStore,
-		oauthURL:  oauthURL,
-		loginURL:  loginURL,
-		publicURL: publicURL,
-		log:       log,
+		clients:         clients,
+		repoStore:       repoStore,
+		oauthURL:        oauthURL,
+		loginURL:        loginURL,
+		providerFor:     providerFor,
+		userProviderFor: userProviderFor,
+		publicURL:       publicURL,
+		log:             log,
 	}
 }
 
 // gitFor returns a configured Bitbucket provider for the Slack team.
 // Returns nil (no error) when the team has not connected Bitbucket yet.
-func (h *Handler) gitFor(teamID string, refreshFn func(rec *store.TokenRecord) (*store.TokenRecord, error)) (provider.Provider, error) {
-	ctx := context.Background()
-	rec, err := h.repoStore.GetToken(ctx, teamID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to look up credentials: %w", err)
-	}
-	if rec == nil {
-		return nil, nil // caller should send connect prompt
-	}
-
-	// Refresh if expiring within 5 minutes.
-	if time.Until(rec.ExpiresAt) < 5*time.Minute {
-		rec, err = refreshFn(rec)
-		if err != nil {
-			return nil, fmt.Errorf("token refresh failed: %w", err)
-		}
-	}
-
-	return provider.NewOAuth(rec.Workspace, rec.AccessToken), nil
+func (h *Handler) gitFor(teamID string) (provider.Provider, error) {
+	return h.providerFor(context.Background(), teamID)
 }
 
 // HandleSlashCommand routes slash commands to the appropriate handler.
-func (h *Handler) HandleSlashCommand(cmd slack.SlashCommand, refreshFn func(rec *store.TokenRecord) (*store.TokenRecord, error)) {
+func (h *Handler) HandleSlashCommand(cmd slack.SlashCommand) {
 	h.log.Info("slash command", "command", cmd.Command, "text", cmd.Text, "user", cmd.UserName, "team", cmd.TeamID)
 
 	switch cmd.Command {
 	case "/repo":
 		h.handleRepoCommand(cmd)
 	default:
-		h.respond(cmd.ChannelID, fmt.Sprintf("Unknown command: `%s`", cmd.Command))
+		h.respond(cmd.TeamID, cmd.ChannelID, fmt.Sprintf("Unknown command: `%s`", cmd.Command))
 	}
 }
 
@@ -86,31 +76,58 @@ func (h *Handler) handleCallbackEvent(event slackevents.EventsAPIEvent) {
 	switch ev := event.InnerEvent.Data.(type) {
 	case *slackevents.AppMentionEvent:
 		h.log.Info("app mention", "user", ev.User, "text", ev.Text)
-		h.respond(ev.Channel, fmt.Sprintf(
+		h.respond(event.TeamID, ev.Channel, fmt.Sprintf(
 			"Hi <@%s>! Use `/repo connect <workspace>` to connect Bitbucket, `/repo add <workspace/repo>` to subscribe a channel, or `/repo list` to see subscriptions.",
 			ev.User,
 		))
+	case *slackevents.AppHomeOpenedEvent:
+		h.handleAppHomeOpened(event.TeamID, ev)
+	case *slackevents.MessageEvent:
+		h.handleThreadReply(event.TeamID, ev)
 	}
 }
 
 // handleRepoCommand handles the /repo slash command with subcommands:
 //
 //	/repo connect <workspace>   — connect Bitbucket account via OAuth
-//	/repo add <workspace/repo>  — subscribe this channel to PR notifications
+//	/repo add <workspace/repo> [filters] — subscribe this channel and register the webhook (ephemeral)
+//	/repo add-workspace <workspace> [filters] — same as /repo add <workspace>/*: every repo, via a workspace webhook
 //	/repo list                  — list subscriptions (ephemeral)
 //	/repo delete                — remove subscriptions via buttons (ephemeral)
+//	/repo prs [workspace/repo]  — list open pull requests (ephemeral)
+//	/repo digest set|show|off|now — schedule the channel's open-PR digest
+//	/repo remind set|show|off   — configure review reminders for a repository
 func (h *Handler) handleRepoCommand(cmd slack.SlashCommand) {
-	const usage = "Usage: `/repo connect <workspace>`, `/repo add <workspace/repo>`, `/repo list`, `/repo delete`"
+	const usage = "Usage: `/repo connect <workspace>`, `/repo add <workspace/repo>`, `/repo add-workspace <workspace>`, `/repo list`, `/repo delete`, `/repo prs [workspace/repo]`, `/repo digest set <days> <HH:MM>`, `/repo remind set <workspace/repo>`"
 
 	parts := strings.Fields(cmd.Text)
 	if len(parts) == 0 {
-		h.respond(cmd.ChannelID, usage)
+		h.respond(cmd.TeamID, cmd.ChannelID, usage)
 		return
 	}
 
 	switch parts[0] {
+	case "add":
+		h.handleRepoAdd(cmd.TeamID, cmd.ChannelID, parts[1:], cmd.ResponseURL, false)
+	case "add-workspace":
+		if len(parts) < 2 {
+			h.postToResponseURL(cmd.ResponseURL, interactionReply{Text: "Usage: `/repo add-workspace <workspace> [filters]`"})
+			return
+		}
+		args := append([]string{store.WorkspaceSlug(strings.Trim(parts[1], "/"))}, parts[2:]...)
+		h.handleRepoAdd(cmd.TeamID, cmd.ChannelID, args, cmd.ResponseURL, false)
+	case "prs":
+		repoArg := ""
+		if len(parts) > 1 {
+			repoArg = parts[1]
+		}
+		h.handleRepoPRs(cmd.TeamID, cmd.ChannelID, repoArg, cmd.ResponseURL, 0, false)
+	case "digest":
+		h.handleRepoDigest(cmd, parts[1:])
+	case "remind":
+		h.handleRepoRemind(cmd, parts[1:])
 	default:
-		h.respond(cmd.ChannelID, usage)
+		h.respond(cmd.TeamID, cmd.ChannelID, usage)
 	}
 }
 
@@ -146,7 +163,7 @@ type interactionReply struct {
 	Blocks          []slack.Block `json:"blocks,omitempty"`
 }
 
-// repoSubResponse handles /repo connect and /repo add inline, returning an ephemeral response.
+// repoSubResponse handles /repo connect, list and delete inline, returning an ephemeral response.
 func (h *Handler) repoSubResponse(cmd slack.SlashCommand) slashResponse {
 	parts := strings.Fields(cmd.Text)
 	switch parts[0] {
@@ -155,68 +172,39 @@ func (h *Handler) repoSubResponse(cmd slack.SlashCommand) slashResponse {
 			return slashResponse{ResponseType: "ephemeral", Text: "Usage: `/repo connect <workspace>`"}
 		}
 		workspace := parts[1]
-		authURL := h.oauthURL(cmd.TeamID, cmd.ChannelID, cmd.UserID, workspace)
-		return slashResponse{
-			ResponseType: "ephemeral",
-			Text: fmt.Sprintf(
-				":key: Click the link below to connect Bitbucket workspace `%s` to this Slack team:\n<%s|Connect Bitbucket>",
-				workspace, authURL,
-			),
-		}
-
-	case "add":
-		if len(parts) < 2 {
-			return slashResponse{ResponseType: "ephemeral", Text: "Usage: `/repo add <workspace/repo>`"}
-		}
-		repoSlug := normalizeRepoSlug(parts[1])
-		ctx := context.Background()
-
-		rec, err := h.repoStore.GetToken(ctx, cmd.TeamID)
+		authURL, err := h.oauthURL(cmd.TeamID, cmd.ChannelID, cmd.UserID, workspace)
 		if err != nil {
-			return slashResponse{ResponseType: "ephemeral", Text: ":x: Failed to check connection status"}
-		}
-		if rec == nil {
-			return slashResponse{ResponseType: "ephemeral", Text: ":warning: Bitbucket is not connected yet. Run `/repo connect <workspace>` to get started."}
-		}
-
-		if err := h.repoStore.Subscribe(ctx, cmd.ChannelID, cmd.TeamID, repoSlug); err != nil {
-			h.log.Error("subscribe repo", "repo", repoSlug, "err", err)
-			return slashResponse{ResponseType: "ephemeral", Text: fmt.Sprintf(":x: Failed to subscribe to `%s`", repoSlug)}
+			h.log.Error("build connect url", "team", cmd.TeamID, "err", err)
+			return slashResponse{ResponseType: "ephemeral", Text: ":x: Failed to start the Bitbucket connection"}
 		}
-
-		secret, err := h.repoStore.GetOrCreateWebhookSecret(ctx, repoSlug)
-		if err != nil {
-			h.log.Error("get webhook secret", "repo", repoSlug, "err", err)
-			return slashResponse{ResponseType: "ephemeral", Text: ":x: Failed to generate webhook secret"}
-		}
-
-		webhookURL := h.publicURL + "/bitbucket/webhook"
 		return slashResponse{
 			ResponseType: "ephemeral",
 			Text: fmt.Sprintf(
-				":white_check_mark: This channel will now receive PR notifications for `%s`.\n\n"+
-					"*Next step:* add this webhook in Bitbucket:\n"+
-					"Repository → Settings → Webhooks → Add webhook\n"+
-					"• URL: `%s`\n"+
-					"• Secret: `%s`\n"+
-					"• Triggers: *ALL*",
-				repoSlug, webhookURL, secret,
+				":key: Click the link below to connect Bitbucket workspace `%s` to this Slack team:\n<%s|Connect Bitbucket>",
+				workspace, authURL,
 			),
 		}
 
 	case "list":
 		ctx := context.Background()
-		repos, err := h.repoStore.ListForChannel(ctx, cmd.ChannelID)
+		subs, err := h.repoStore.SubscriptionsForChannel(ctx, cmd.ChannelID)
 		if err != nil {
 			return slashResponse{ResponseType: "ephemeral", Text: ":x: Failed to fetch subscriptions"}
 		}
-		if len(repos) == 0 {
+		if len(subs) == 0 {
 			return slashResponse{ResponseType: "ephemeral", Text: "No repositories subscribed in this channel."}
 		}
 		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("*Subscribed repositories (%d)*\n", len(repos)))
-		for _, r := range repos {
-			sb.WriteString(fmt.Sprintf("• `%s`\n", normalizeRepoSlug(r)))
+		sb.WriteString(fmt.Sprintf("*Subscribed repositories (%d)*\n", len(subs)))
+		for _, sub := range subs {
+			sb.WriteString(fmt.Sprintf("• `%s`", normalizeRepoSlug(sub.RepoSlug)))
+			if store.IsWorkspaceSlug(sub.RepoSlug) {
+				sb.WriteString(" (all repositories)")
+			}
+			if !sub.Filter.IsZero() {
+				sb.WriteString(" — " + sub.Filter.String())
+			}
+			sb.WriteString("\n")
 		}
 		return slashResponse{ResponseType: "ephemeral", Text: sb.String()}
 
@@ -230,7 +218,7 @@ func (h *Handler) repoSubResponse(cmd slack.SlashCommand) slashResponse {
 
 	}
 
-	return slashResponse{ResponseType: "ephemeral", Text: "Usage: `/repo connect <workspace>`, `/repo add <workspace/repo>`, `/repo list`, `/repo delete`"}
+	return slashResponse{ResponseType: "ephemeral", Text: "Usage: `/repo connect <workspace>`, `/repo add <workspace/repo>`, `/repo add-workspace <workspace>`, `/repo list`, `/repo delete`, `/repo prs [workspace/repo]`, `/repo digest set <days> <HH:MM>`, `/repo remind set <workspace/repo>`"}
 }
 
 // buildRepoDeleteBlocks builds a Block Kit list of repos with a Delete button on each row.
@@ -276,11 +264,23 @@ func (h *Handler) HandleInteraction(payload slack.InteractionCallback) {
 	}
 
 	for _, action := range payload.ActionCallback.BlockActions {
-		if action.ActionID == "repo_delete" {
+		switch action.ActionID {
+		case "repo_prs_page":
+			page, repoArg := parsePRsPageValue(action.Value)
+			h.handleRepoPRs(payload.Team.ID, payload.Channel.ID, repoArg, payload.ResponseURL, page, true)
+		case ActionPRApprove, ActionPRRequestChanges:
+			h.handlePRAction(payload, action)
+		case ActionPROpenDiff:
+			// link button, nothing to do
+		case "notify_toggle":
+			h.handleNotifyToggle(payload, action.Value)
+		case "repo_add":
+			h.handleRepoAdd(payload.Team.ID, payload.Channel.ID, strings.Fields(action.Value), payload.ResponseURL, true)
+		case "repo_delete":
 			channelID := payload.Channel.ID
 			repoSlug := action.Value
 
-			if err := h.repoStore.Unsubscribe(context.Background(), channelID, repoSlug); err != nil {
+			if err := h.unsubscribeRepo(context.Background(), payload.Team.ID, channelID, repoSlug); err != nil {
 				h.log.Error("unsubscribe repo via button", "repo", repoSlug, "err", err)
 			}
 
@@ -295,8 +295,10 @@ func (h *Handler) HandleInteraction(payload slack.InteractionCallback) {
 				ReplaceOriginal: true,
 				Blocks:          append([]slack.Block{confirm, slack.NewDividerBlock()}, buildRepoDeleteBlocks(repos)...),
 			})
-			return
+		default:
+			continue
 		}
+		return
 	}
 }
 
@@ -323,7 +325,11 @@ func (h *Handler) loginResponse(cmd slack.SlashCommand) slashResponse {
 			Text:         ":lock: `/login` can only be used in a direct message with the bot.",
 		}
 	}
-	authURL := h.loginURL(cmd.UserID, cmd.ChannelID)
+	authURL, err := h.loginURL(cmd.TeamID, cmd.UserID, cmd.ChannelID)
+	if err != nil {
+		h.log.Error("build login url", "user", cmd.UserID, "err", err)
+		return slashResponse{ResponseType: "ephemeral", Text: ":x: Failed to start the Bitbucket login"}
+	}
 	return slashResponse{
 		ResponseType: "ephemeral",
 		Text: fmt.Sprintf(
@@ -333,8 +339,13 @@ func (h *Handler) loginResponse(cmd slack.SlashCommand) slashResponse {
 	}
 }
 
-func (h *Handler) respond(channelID, text string) {
-	_, _, err := h.client.PostMessage(channelID, slack.MsgOptionText(text, false))
+func (h *Handler) respond(teamID, channelID, text string) {
+	client, err := h.clients.For(context.Background(), teamID)
+	if err != nil {
+		h.log.Error("resolve slack client", "team", teamID, "err", err)
+		return
+	}
+	_, _, err = client.PostMessage(channelID, slack.MsgOptionText(text, false))
 	if err != nil {
 		h.log.Error("failed to post message", "channel", channelID, "err", err)
 	}
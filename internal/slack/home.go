@@ -0,0 +1,142 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"bitbucket-slack-bot/internal/store"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+const (
+	// homeMergedWindow is how far back "Recently merged" on the App Home reaches.
+	homeMergedWindow = 7 * 24 * time.Hour
+	// homeSectionLimit caps the PRs per App Home section; a view holds at most 100 blocks.
+	homeSectionLimit = 15
+)
+
+// handleAppHomeOpened remembers that the user has an App Home to keep current and publishes it.
+func (h *Handler) handleAppHomeOpened(teamID string, ev *slackevents.AppHomeOpenedEvent) {
+	if ev.Tab != "home" {
+		return
+	}
+	ctx := context.Background()
+	if err := h.repoStore.SaveHomeView(ctx, ev.User, teamID); err != nil {
+		h.log.Error("save home view", "user", ev.User, "err", err)
+	}
+	if err := h.PublishHome(ctx, teamID, ev.User); err != nil {
+		h.log.Error("publish app home", "user", ev.User, "err", err)
+	}
+}
+
+// RefreshHomes republishes the App Home of every user in teamIDs linked to one of
+// bitbucketNames who has opened it. The webhook handler calls it whenever one of their
+// PRs changes.
+func (h *Handler) RefreshHomes(ctx context.Context, teamIDs, bitbucketNames []string) {
+	viewers, err := h.repoStore.HomeViewers(ctx, teamIDs, bitbucketNames)
+	if err != nil {
+		h.log.Error("list home viewers", "err", err)
+		return
+	}
+	for _, v := range viewers {
+		if err := h.PublishHome(ctx, v.TeamID, v.SlackUserID); err != nil {
+			h.log.Error("publish app home", "user", v.SlackUserID, "err", err)
+		}
+	}
+}
+
+// PublishHome renders the App Home tab for a Slack user from the stored PR state.
+func (h *Handler) PublishHome(ctx context.Context, teamID, userID string) error {
+	client, err := h.clients.For(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	blocks, err := h.buildHomeBlocks(ctx, teamID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = client.PublishViewContext(ctx, slack.PublishViewContextRequest{
+		UserID: userID,
+		View:   slack.HomeTabViewRequest{Type: slack.VTHomeTab, Blocks: slack.Blocks{BlockSet: blocks}},
+	})
+	return err
+}
+
+func (h *Handler) buildHomeBlocks(ctx context.Context, teamID, userID string, now time.Time) ([]slack.Block, error) {
+	bbUser, err := h.repoStore.GetBitbucketUser(ctx, teamID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bbUser == "" {
+		return []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			":link: Link your Bitbucket account to see your review queue here: run `/login` in the *Messages* tab.",
+			false, false), nil, nil)}, nil
+	}
+
+	prs, err := h.repoStore.ListUserPRs(ctx, bbUser, now.Add(-homeMergedWindow))
+	if err != nil {
+		return nil, err
+	}
+	var reviewing, mine, merged []store.OpenPR
+	for _, pr := range prs {
+		switch {
+		case pr.State == store.PRMerged:
+			merged = append(merged, pr)
+		case pr.AuthorName == bbUser:
+			mine = append(mine, pr)
+		case !slices.Contains(pr.Approvals, bbUser) && !slices.Contains(pr.ChangeRequests, bbUser):
+			reviewing = append(reviewing, pr)
+		}
+	}
+	slices.Reverse(merged) // newest merge first
+
+	blocks := []slack.Block{
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("Pull requests for *%s* · updated %s", bbUser, now.UTC().Format("Jan 2 15:04 UTC")), false, false)),
+	}
+	blocks = appendHomeSection(blocks, ":eyes: PRs waiting on my review", reviewing, "Nothing waiting on you :tada:",
+		func(pr store.OpenPR) string {
+			return fmt.Sprintf("*<%s|#%d %s>*  `%s`\nby %s · opened %s ago",
+				pr.URL, pr.PRID, pr.Title, pr.RepoSlug, pr.AuthorName, formatAge(now.Sub(pr.CreatedAt)))
+		})
+	blocks = appendHomeSection(blocks, ":memo: My open PRs", mine, "You have no open pull requests.",
+		func(pr store.OpenPR) string {
+			build := "—"
+			if pr.BuildState != "" {
+				build = buildStateLabel(pr.BuildState)
+			}
+			line := fmt.Sprintf("*<%s|#%d %s>*  `%s`\nOpened %s ago · :white_check_mark: %d approval%s · Build: %s",
+				pr.URL, pr.PRID, pr.Title, pr.RepoSlug, formatAge(now.Sub(pr.CreatedAt)),
+				len(pr.Approvals), plural(len(pr.Approvals)), build)
+			if len(pr.ChangeRequests) > 0 {
+				line += " · :warning: changes requested"
+			}
+			return line
+		})
+	blocks = appendHomeSection(blocks, ":tada: Recently merged", merged, "Nothing merged in the last week.",
+		func(pr store.OpenPR) string {
+			return fmt.Sprintf("*<%s|#%d %s>*  `%s`\nMerged %s ago", pr.URL, pr.PRID, pr.Title, pr.RepoSlug, formatAge(now.Sub(pr.UpdatedAt)))
+		})
+	return blocks, nil
+}
+
+// appendHomeSection adds a header and one block per PR, or the empty text when there are none.
+func appendHomeSection(blocks []slack.Block, title string, prs []store.OpenPR, empty string, format func(store.OpenPR) string) []slack.Block {
+	blocks = append(blocks, slack.NewHeaderBlock(
+		slack.NewTextBlockObject(slack.PlainTextType, fmt.Sprintf("%s (%d)", title, len(prs)), true, false)))
+	if len(prs) == 0 {
+		return append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, empty, false, false)))
+	}
+	for _, pr := range prs[:min(len(prs), homeSectionLimit)] {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, format(pr), false, false), nil, nil))
+	}
+	if extra := len(prs) - homeSectionLimit; extra > 0 {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("…and %d more", extra), false, false)))
+	}
+	return append(blocks, slack.NewDividerBlock())
+}
@@ -0,0 +1,150 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"bitbucket-slack-bot/internal/provider"
+	"bitbucket-slack-bot/internal/store"
+)
+
+// webhookDescription labels the webhooks the bot registers in Bitbucket.
+const webhookDescription = "Slack PR notifications"
+
+// webhookURL is the endpoint Bitbucket delivers events to.
+func (h *Handler) webhookURL() string {
+	return h.publicURL + "/bitbucket/webhook"
+}
+
+// hookAPI is the webhook collection for one subscription slug: a repository's own
+// webhooks, or the workspace's for a workspace-wide slug.
+type hookAPI struct {
+	list   func(ctx context.Context) ([]provider.Webhook, error)
+	create func(ctx context.Context, hook provider.Webhook, secret string) (*provider.Webhook, error)
+	update func(ctx context.Context, hook provider.Webhook, secret string) (*provider.Webhook, error)
+	delete func(ctx context.Context, uuid string) error
+}
+
+// hooksFor returns the webhook collection that serves repoSlug.
+func hooksFor(git provider.Provider, repoSlug string) (hookAPI, error) {
+	workspace, name, _ := strings.Cut(repoSlug, "/")
+	if !strings.EqualFold(workspace, git.Workspace()) {
+		return hookAPI{}, fmt.Errorf("repository is not in connected workspace %q", git.Workspace())
+	}
+	if store.IsWorkspaceSlug(repoSlug) {
+		return hookAPI{
+			list:   git.ListWorkspaceWebhooks,
+			create: git.CreateWorkspaceWebhook,
+			update: git.UpdateWorkspaceWebhook,
+			delete: git.DeleteWorkspaceWebhook,
+		}, nil
+	}
+	return hookAPI{
+		list: func(ctx context.Context) ([]provider.Webhook, error) { return git.ListWebhooks(ctx, name) },
+		create: func(ctx context.Context, hook provider.Webhook, secret string) (*provider.Webhook, error) {
+			return git.CreateWebhook(ctx, name, hook, secret)
+		},
+		update: func(ctx context.Context, hook provider.Webhook, secret string) (*provider.Webhook, error) {
+			return git.UpdateWebhook(ctx, name, hook, secret)
+		},
+		delete: func(ctx context.Context, uuid string) error { return git.DeleteWebhook(ctx, name, uuid) },
+	}, nil
+}
+
+// ensureWebhook registers the bot's webhook for repoSlug (a repository or a workspace
+// slug), or brings an existing one (matched by URL) up to date with the current events and secret.
+func (h *Handler) ensureWebhook(ctx context.Context, git provider.Provider, repoSlug, secret string) error {
+	api, err := hooksFor(git, repoSlug)
+	if err != nil {
+		return err
+	}
+	hooks, err := api.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	want := provider.Webhook{
+		URL:         h.webhookURL(),
+		Description: webhookDescription,
+		Active:      true,
+		Events:      provider.WebhookEvents,
+	}
+	for _, hook := range hooks {
+		if hook.URL != want.URL {
+			continue
+		}
+		// The secret is never returned by the API, so always rewrite it.
+		want.UUID = hook.UUID
+		_, err = api.update(ctx, want, secret)
+		return err
+	}
+	_, err = api.create(ctx, want, secret)
+	return err
+}
+
+// removeWebhook deletes the bot's webhook for repoSlug. A hook that is already gone is not an error.
+func (h *Handler) removeWebhook(ctx context.Context, git provider.Provider, repoSlug string) error {
+	api, err := hooksFor(git, repoSlug)
+	if err != nil {
+		return err
+	}
+	hooks, err := api.list(ctx)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if hook.URL != h.webhookURL() {
+			continue
+		}
+		if err := api.delete(ctx, hook.UUID); err != nil && !errors.Is(err, provider.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsubscribeRepo removes channelID's subscription to repoSlug and, once no channel
+// in any team is subscribed to it any more, deletes its webhook.
+func (h *Handler) unsubscribeRepo(ctx context.Context, teamID, channelID, repoSlug string) error {
+	if err := h.repoStore.Unsubscribe(ctx, channelID, repoSlug); err != nil {
+		return err
+	}
+
+	remaining, err := h.repoStore.HasSubscribers(ctx, repoSlug)
+	if err != nil || remaining {
+		return err
+	}
+
+	git, err := h.gitFor(teamID)
+	if err != nil || git == nil {
+		h.log.Warn("cannot remove webhook without a Bitbucket connection", "repo", repoSlug, "err", err)
+		return nil
+	}
+	if err := h.removeWebhook(ctx, git, repoSlug); err != nil {
+		h.log.Error("remove webhook", "repo", repoSlug, "err", err)
+	}
+	return nil
+}
+
+// manualWebhookInstructions explains how to add the webhook for repoSlug by hand, with
+// a hint about why automatic registration failed when the cause is recognisable.
+func manualWebhookInstructions(repoSlug, webhookURL, secret string, err error) string {
+	scope, settings := "repository", "Repository → Settings → Webhooks → Add webhook"
+	if store.IsWorkspaceSlug(repoSlug) {
+		scope, settings = "workspace", "Workspace settings → Webhooks → Add webhook"
+	}
+	reason := "The webhook could not be registered automatically"
+	if errors.Is(err, provider.ErrForbidden) || errors.Is(err, provider.ErrUnauthorized) {
+		reason += fmt.Sprintf(" (the connected Bitbucket account needs admin access to the %s)", scope)
+	}
+	return fmt.Sprintf(
+		"%s. Add it in Bitbucket:\n"+
+			"%s\n"+
+			"• URL: `%s`\n"+
+			"• Secret: `%s`\n"+
+			"• Triggers: *Pull request* (all) and *Repository → Build status created/updated*",
+		reason, settings, webhookURL, secret,
+	)
+}
@@ -0,0 +1,114 @@
+package slack
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"bitbucket-slack-bot/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/slack-go/slack"
+)
+
+// botScopes are the bot token scopes requested by the "Add to Slack" flow.
+var botScopes = []string{
+	"chat:write",
+	"chat:write.public",
+	"commands",
+	"app_mentions:read",
+	"im:write",
+	"users:read",
+	"channels:history",
+	"groups:history",
+}
+
+// installStateTTL bounds how long an "Add to Slack" link stays valid.
+const installStateTTL = 10 * time.Minute
+
+// InstallHandler implements the Slack OAuth v2 "Add to Slack" flow and stores a
+// bot token per team.
+type InstallHandler struct {
+	clientID     string
+	clientSecret string
+	publicURL    string
+	repoStore    *store.RepoStore
+	clients      *Clients
+	log          *slog.Logger
+}
+
+func NewInstallHandler(clientID, clientSecret, publicURL string, repoStore *store.RepoStore, clients *Clients, log *slog.Logger) *InstallHandler {
+	return &InstallHandler{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		publicURL:    publicURL,
+		repoStore:    repoStore,
+		clients:      clients,
+		log:          log,
+	}
+}
+
+func (h *InstallHandler) redirectURI() string {
+	return h.publicURL + "/slack/oauth/callback"
+}
+
+// HandleInstall redirects the browser to Slack's authorize page.
+func (h *InstallHandler) HandleInstall(c *fiber.Ctx) error {
+	nonce, err := h.repoStore.CreateOAuthState(c.Context(), store.OAuthState{Kind: "slack_install"}, installStateTTL)
+	if err != nil {
+		h.log.Error("create install state", "err", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("internal error")
+	}
+	authURL := fmt.Sprintf(
+		"https://slack.com/oauth/v2/authorize?client_id=%s&scope=%s&redirect_uri=%s&state=%s",
+		url.QueryEscape(h.clientID),
+		url.QueryEscape(strings.Join(botScopes, ",")),
+		url.QueryEscape(h.redirectURI()),
+		url.QueryEscape(nonce),
+	)
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// HandleCallback exchanges the authorization code for a bot token and stores it for the team.
+func (h *InstallHandler) HandleCallback(c *fiber.Ctx) error {
+	if e := c.Query("error"); e != "" {
+		return c.Status(fiber.StatusBadRequest).SendString("installation cancelled: " + e)
+	}
+	code := c.Query("code")
+	nonce := c.Query("state")
+	if code == "" || nonce == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("missing code or state")
+	}
+
+	st, err := h.repoStore.ConsumeOAuthState(c.Context(), nonce)
+	if err != nil {
+		h.log.Error("consume install state", "err", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("internal error")
+	}
+	if st == nil || st.Kind != "slack_install" {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid or expired state — start the installation again")
+	}
+
+	resp, err := slack.GetOAuthV2ResponseContext(c.Context(), http.DefaultClient, h.clientID, h.clientSecret, code, h.redirectURI())
+	if err != nil {
+		h.log.Error("slack oauth exchange failed", "err", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to exchange code")
+	}
+
+	if err := h.repoStore.SaveSlackInstallation(c.Context(), store.SlackInstallation{
+		TeamID:    resp.Team.ID,
+		TeamName:  resp.Team.Name,
+		BotUserID: resp.BotUserID,
+		BotToken:  resp.AccessToken,
+	}); err != nil {
+		h.log.Error("save slack installation", "team", resp.Team.ID, "err", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save installation")
+	}
+	h.clients.Set(resp.Team.ID, resp.AccessToken)
+
+	h.log.Info("slack app installed", "team", resp.Team.ID, "team_name", resp.Team.Name)
+	return c.SendString(fmt.Sprintf("Installed into %s! Return to Slack and run /repo connect <workspace>.", resp.Team.Name))
+}
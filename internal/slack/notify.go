@@ -0,0 +1,135 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"bitbucket-slack-bot/internal/store"
+
+	"github.com/slack-go/slack"
+)
+
+const notifyUsage = "Usage: `/notify` to show your settings, `/notify on <kind,…|all>`, `/notify off <kind,…|all>`. Kinds: `reviewer_added`, `approved`, `changes_requested`, `comment`, `build_failed`"
+
+// notifyLabels describes each personal notification kind for /notify.
+var notifyLabels = map[string][2]string{
+	store.NotifyReviewerAdded:    {"Reviewer added", "someone adds you as a reviewer"},
+	store.NotifyApproved:         {"Approved", "your pull request is approved"},
+	store.NotifyChangesRequested: {"Changes requested", "a reviewer requests changes on your pull request"},
+	store.NotifyComment:          {"Comment", "someone comments on your pull request"},
+	store.NotifyBuildFailed:      {"Build failed", "a build fails on your pull request"},
+}
+
+// notifyResponse builds an ephemeral inline response for the /notify command:
+// it applies "on"/"off" arguments, then shows the user's toggles.
+func (h *Handler) notifyResponse(cmd slack.SlashCommand) slashResponse {
+	ctx := context.Background()
+	ephemeral := func(text string) slashResponse {
+		return slashResponse{ResponseType: "ephemeral", Text: text}
+	}
+
+	bbUser, err := h.repoStore.GetBitbucketUser(ctx, cmd.TeamID, cmd.UserID)
+	if err != nil {
+		h.log.Error("get bitbucket user", "user", cmd.UserID, "err", err)
+		return ephemeral(":x: Failed to load your notification settings")
+	}
+	if bbUser == "" {
+		return ephemeral(":link: Link your Bitbucket account first: run `/login` in a direct message with the bot.")
+	}
+
+	parts := strings.Fields(cmd.Text)
+	var note string
+	if len(parts) > 0 && parts[0] != "show" {
+		if len(parts) != 2 || (parts[0] != "on" && parts[0] != "off") {
+			return ephemeral(notifyUsage)
+		}
+		kinds := store.NotifyKinds
+		if parts[1] != "all" {
+			kinds = strings.Split(parts[1], ",")
+			for _, k := range kinds {
+				if _, ok := notifyLabels[k]; !ok {
+					return ephemeral(fmt.Sprintf(":warning: Unknown notification kind `%s`\n%s", k, notifyUsage))
+				}
+			}
+		}
+		if err := h.setNotifications(ctx, cmd.TeamID, cmd.UserID, parts[0] == "on", kinds); err != nil {
+			h.log.Error("save notification prefs", "user", cmd.UserID, "err", err)
+			return ephemeral(":x: Failed to save your notification settings")
+		}
+		note = ":white_check_mark: Saved."
+	}
+
+	enabled, err := h.repoStore.GetNotificationPrefs(ctx, cmd.UserID)
+	if err != nil {
+		h.log.Error("get notification prefs", "user", cmd.UserID, "err", err)
+		return ephemeral(":x: Failed to load your notification settings")
+	}
+	return slashResponse{ResponseType: "ephemeral", Text: "Personal notifications", Blocks: buildNotifyBlocks(bbUser, enabled, note)}
+}
+
+// setNotifications turns kinds on or off for the user, keeping their other settings.
+func (h *Handler) setNotifications(ctx context.Context, teamID, userID string, on bool, kinds []string) error {
+	enabled, err := h.repoStore.GetNotificationPrefs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var next []string
+	for _, k := range store.NotifyKinds {
+		if slices.Contains(kinds, k) && on || !slices.Contains(kinds, k) && slices.Contains(enabled, k) {
+			next = append(next, k)
+		}
+	}
+	return h.repoStore.SaveNotificationPrefs(ctx, userID, teamID, next)
+}
+
+// handleNotifyToggle applies a notify_toggle button ("on:kind" or "off:kind") and
+// replaces the settings message.
+func (h *Handler) handleNotifyToggle(payload slack.InteractionCallback, value string) {
+	ctx := context.Background()
+	state, kind, _ := strings.Cut(value, ":")
+	if err := h.setNotifications(ctx, payload.Team.ID, payload.User.ID, state == "on", []string{kind}); err != nil {
+		h.log.Error("save notification prefs", "user", payload.User.ID, "err", err)
+		h.postToResponseURL(payload.ResponseURL, interactionReply{Text: ":x: Failed to save your notification settings"})
+		return
+	}
+	bbUser, _ := h.repoStore.GetBitbucketUser(ctx, payload.Team.ID, payload.User.ID)
+	enabled, err := h.repoStore.GetNotificationPrefs(ctx, payload.User.ID)
+	if err != nil {
+		h.log.Error("get notification prefs", "user", payload.User.ID, "err", err)
+		return
+	}
+	h.postToResponseURL(payload.ResponseURL, interactionReply{
+		ReplaceOriginal: true,
+		Text:            "Personal notifications",
+		Blocks:          buildNotifyBlocks(bbUser, enabled, ""),
+	})
+}
+
+// buildNotifyBlocks renders one row per notification kind with an on/off button.
+func buildNotifyBlocks(bbUser string, enabled []string, note string) []slack.Block {
+	header := fmt.Sprintf("*Personal notifications*\nDMs about pull requests that involve you as *%s*, with a link to the channel thread.", bbUser)
+	if note != "" {
+		header = note + "\n" + header
+	}
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, header, false, false), nil, nil),
+		slack.NewDividerBlock(),
+	}
+	for _, kind := range store.NotifyKinds {
+		label := notifyLabels[kind]
+		on := slices.Contains(enabled, kind)
+		icon, btnText, value := ":no_bell:", "Turn on", "on:"+kind
+		if on {
+			icon, btnText, value = ":bell:", "Turn off", "off:"+kind
+		}
+		btn := slack.NewButtonBlockElement("notify_toggle", value,
+			slack.NewTextBlockObject(slack.PlainTextType, btnText, false, false))
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("%s *%s*\nWhen %s", icon, label[0], label[1]), false, false),
+			nil, slack.NewAccessory(btn),
+		))
+	}
+	return blocks
+}
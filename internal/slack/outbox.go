@@ -0,0 +1,284 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"bitbucket-slack-bot/internal/store"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// outboxLease is how long a claimed message stays invisible to other workers.
+	outboxLease = time.Minute
+	// outboxIdlePoll is how long a worker sleeps when the queue is empty.
+	outboxIdlePoll = 500 * time.Millisecond
+	// outboxMaxAttempts caps retries for transient errors; rate limits do not count.
+	outboxMaxAttempts = 8
+	// outboxMaxBackoff caps the exponential backoff between attempts.
+	outboxMaxBackoff = 5 * time.Minute
+	// outboxFailedRetention is how long permanently failed messages are kept for inspection.
+	outboxFailedRetention = 7 * 24 * time.Hour
+	// dmCardAttempts is how many attempts a DM waits for its PR card to be posted
+	// so it can link to the thread; after that it is sent without the link.
+	dmCardAttempts = 3
+)
+
+// errCardPending delays a DM whose PR card has not been delivered yet.
+var errCardPending = errors.New("PR card not posted yet")
+
+// permanentSlackErrors are Slack API error codes that retrying cannot fix.
+var permanentSlackErrors = map[string]bool{
+	"channel_not_found":   true,
+	"not_in_channel":      true,
+	"is_archived":         true,
+	"invalid_blocks":      true,
+	"msg_too_long":        true,
+	"message_not_found":   true,
+	"cant_update_message": true,
+	"account_inactive":    true,
+	"token_revoked":       true,
+	"invalid_auth":        true,
+}
+
+// Outbox delivers queued Slack messages from the slack_outbox table with retries,
+// exponential backoff and Retry-After handling, preserving per-channel order.
+type Outbox struct {
+	repoStore *store.RepoStore
+	clients   *Clients
+	log       *slog.Logger
+}
+
+func NewOutbox(repoStore *store.RepoStore, clients *Clients, log *slog.Logger) *Outbox {
+	return &Outbox{repoStore: repoStore, clients: clients, log: log}
+}
+
+// PostCard queues a new PR card; its ts is saved as the PR message once delivered.
+func (o *Outbox) PostCard(ctx context.Context, teamID, channelID, repoSlug string, prID int, blocks []slack.Block) error {
+	return o.enqueue(ctx, store.OutboxMessage{
+		TeamID: teamID, ChannelID: channelID, Kind: store.OutboxPost,
+		RepoSlug: repoSlug, PRID: prID,
+	}, blocks)
+}
+
+// UpdateCard queues a replacement of the PR card in channelID.
+func (o *Outbox) UpdateCard(ctx context.Context, teamID, channelID, repoSlug string, prID int, blocks []slack.Block) error {
+	return o.enqueue(ctx, store.OutboxMessage{
+		TeamID: teamID, ChannelID: channelID, Kind: store.OutboxUpdate,
+		RepoSlug: repoSlug, PRID: prID,
+	}, blocks)
+}
+
+// Reply queues a thread reply under the PR card in channelID.
+func (o *Outbox) Reply(ctx context.Context, teamID, channelID, repoSlug string, prID int, text string) error {
+	return o.enqueue(ctx, store.OutboxMessage{
+		TeamID: teamID, ChannelID: channelID, Kind: store.OutboxReply,
+		RepoSlug: repoSlug, PRID: prID, Text: text,
+	}, nil)
+}
+
+// DM queues a direct message to userID about a PR. A link to the PR card's
+// thread in one of the team's channels is appended at delivery time.
+func (o *Outbox) DM(ctx context.Context, teamID, userID, repoSlug string, prID int, text string) error {
+	return o.enqueue(ctx, store.OutboxMessage{
+		TeamID: teamID, ChannelID: userID, Kind: store.OutboxDM,
+		RepoSlug: repoSlug, PRID: prID, Text: text,
+	}, nil)
+}
+
+func (o *Outbox) enqueue(ctx context.Context, m store.OutboxMessage, blocks []slack.Block) error {
+	if len(blocks) > 0 {
+		b, err := json.Marshal(blocks)
+		if err != nil {
+			return fmt.Errorf("marshal blocks: %w", err)
+		}
+		m.Blocks = b
+	}
+	return o.repoStore.EnqueueOutbox(ctx, m)
+}
+
+// Run starts the given number of delivery goroutines and blocks until ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (o *Outbox) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		msg, err := o.repoStore.ClaimOutbox(ctx, outboxLease)
+		if err != nil && ctx.Err() == nil {
+			o.log.Error("claim outbox message", "err", err)
+		}
+		if msg == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(outboxIdlePoll):
+			}
+			continue
+		}
+		o.process(ctx, msg)
+	}
+}
+
+// process delivers msg and records the outcome: delete on success, reschedule on
+// transient errors and rate limits, mark failed on permanent errors. Every claim
+// counts as an attempt except those that were rate limited.
+func (o *Outbox) process(ctx context.Context, msg *store.OutboxMessage) {
+	err := o.deliver(ctx, msg)
+	if err == nil {
+		if err := o.repoStore.CompleteOutbox(ctx, msg.ID); err != nil {
+			o.log.Error("complete outbox message", "id", msg.ID, "err", err)
+		}
+		return
+	}
+
+	var rateLimited *slack.RateLimitedError
+	var slackErr slack.SlackErrorResponse
+	switch {
+	case errors.As(err, &rateLimited):
+		o.log.Warn("slack rate limited", "channel", msg.ChannelID, "retry_after", rateLimited.RetryAfter)
+		if err := o.repoStore.DeferOutbox(ctx, msg.ID, time.Now().Add(rateLimited.RetryAfter), err.Error()); err != nil {
+			o.log.Error("reschedule outbox message", "id", msg.ID, "err", err)
+		}
+	case errors.As(err, &slackErr) && permanentSlackErrors[slackErr.Err]:
+		o.fail(ctx, msg, err)
+	case msg.Attempts >= outboxMaxAttempts:
+		o.fail(ctx, msg, err)
+	default:
+		o.log.Warn("slack delivery failed, retrying", "channel", msg.ChannelID, "kind", msg.Kind, "attempt", msg.Attempts, "err", err)
+		o.retry(ctx, msg, backoff(msg.Attempts), err)
+	}
+}
+
+func (o *Outbox) retry(ctx context.Context, msg *store.OutboxMessage, after time.Duration, cause error) {
+	if err := o.repoStore.RetryOutbox(ctx, msg.ID, time.Now().Add(after), cause.Error()); err != nil {
+		o.log.Error("reschedule outbox message", "id", msg.ID, "err", err)
+	}
+}
+
+func (o *Outbox) fail(ctx context.Context, msg *store.OutboxMessage, cause error) {
+	o.log.Error("slack delivery failed permanently", "channel", msg.ChannelID, "kind", msg.Kind, "attempts", msg.Attempts, "err", cause)
+	if err := o.repoStore.FailOutbox(ctx, msg.ID, cause.Error()); err != nil {
+		o.log.Error("mark outbox message failed", "id", msg.ID, "err", err)
+	}
+}
+
+// PurgeFailed deletes permanently failed messages older than outboxFailedRetention.
+// It is registered with the scheduler.
+func (o *Outbox) PurgeFailed(ctx context.Context, now time.Time) {
+	n, err := o.repoStore.PurgeFailedOutbox(ctx, now.Add(-outboxFailedRetention))
+	if err != nil {
+		if ctx.Err() == nil {
+			o.log.Error("purge failed outbox messages", "err", err)
+		}
+		return
+	}
+	if n > 0 {
+		o.log.Info("purged failed outbox messages", "count", n)
+	}
+}
+
+// backoff returns an exponential delay with jitter for the given attempt number.
+func backoff(attempt int) time.Duration {
+	d := time.Second << min(attempt, 9)
+	d = min(d, outboxMaxBackoff)
+	return d/2 + rand.N(d/2+1)
+}
+
+func (o *Outbox) deliver(ctx context.Context, msg *store.OutboxMessage) error {
+	client, err := o.clients.For(ctx, msg.TeamID)
+	if err != nil {
+		return err
+	}
+
+	var opts []slack.MsgOption
+	if len(msg.Blocks) > 0 {
+		var blocks slack.Blocks
+		if err := json.Unmarshal(msg.Blocks, &blocks); err != nil {
+			return fmt.Errorf("unmarshal blocks: %w", err)
+		}
+		opts = append(opts, slack.MsgOptionBlocks(blocks.BlockSet...))
+	}
+	if msg.Text != "" {
+		opts = append(opts, slack.MsgOptionText(msg.Text, false))
+	}
+
+	if msg.Kind == store.OutboxDM {
+		return o.deliverDM(ctx, client, msg)
+	}
+
+	if msg.Kind == store.OutboxPost {
+		_, ts, err := client.PostMessageContext(ctx, msg.ChannelID, opts...)
+		if err != nil {
+			return err
+		}
+		if msg.RepoSlug != "" {
+			if err := o.repoStore.SavePRMessage(ctx, msg.RepoSlug, msg.PRID, msg.TeamID, msg.ChannelID, ts); err != nil {
+				o.log.Error("save PR message ts", "repo", msg.RepoSlug, "pr", msg.PRID, "err", err)
+			}
+		}
+		return nil
+	}
+
+	// Updates and replies need the card; it was queued earlier in this channel,
+	// so if it is still missing it was never delivered and there is nothing to thread under.
+	ts, err := o.repoStore.GetPRMessageTS(ctx, msg.RepoSlug, msg.PRID, msg.ChannelID)
+	if err != nil {
+		return err
+	}
+	if ts == "" {
+		o.log.Warn("no PR card to thread under, dropping", "channel", msg.ChannelID, "repo", msg.RepoSlug, "pr", msg.PRID, "kind", msg.Kind)
+		return nil
+	}
+
+	switch msg.Kind {
+	case store.OutboxUpdate:
+		_, _, _, err = client.UpdateMessageContext(ctx, msg.ChannelID, ts, opts...)
+	case store.OutboxReply:
+		_, _, err = client.PostMessageContext(ctx, msg.ChannelID, append(opts, slack.MsgOptionTS(ts))...)
+	default:
+		o.log.Error("unknown outbox message kind, dropping", "id", msg.ID, "kind", msg.Kind)
+	}
+	return err
+}
+
+// deliverDM posts a DM, linking to the team's PR card thread when there is one.
+// Posting to a user ID delivers to the bot's DM with that user.
+func (o *Outbox) deliverDM(ctx context.Context, client *slack.Client, msg *store.OutboxMessage) error {
+	text := msg.Text
+	if msg.RepoSlug != "" {
+		cards, err := o.repoStore.GetPRMessages(ctx, msg.RepoSlug, msg.PRID)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(cards, func(m store.PRMessage) bool { return m.TeamID == msg.TeamID })
+		switch {
+		case i >= 0:
+			link, err := client.GetPermalinkContext(ctx, &slack.PermalinkParameters{Channel: cards[i].ChannelID, Ts: cards[i].MessageTS})
+			if err != nil {
+				o.log.Warn("get PR card permalink", "channel", cards[i].ChannelID, "err", err)
+			} else {
+				text += fmt.Sprintf("\n<%s|View the thread> in <#%s>", link, cards[i].ChannelID)
+			}
+		case msg.Attempts < dmCardAttempts:
+			return errCardPending
+		}
+	}
+	_, _, err := client.PostMessageContext(ctx, msg.ChannelID, slack.MsgOptionText(text, false))
+	return err
+}
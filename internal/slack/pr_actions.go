@@ -0,0 +1,84 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"bitbucket-slack-bot/internal/provider"
+
+	"github.com/slack-go/slack"
+)
+
+// Action IDs of the buttons on PR cards.
+const (
+	ActionPRApprove        = "pr_approve"
+	ActionPRRequestChanges = "pr_request_changes"
+	ActionPROpenDiff       = "pr_open_diff" // a link button; Slack still reports the click
+)
+
+// PRActionValue encodes the PR a card button acts on, e.g. "acme/web#42".
+func PRActionValue(repoSlug string, prID int) string {
+	return repoSlug + "#" + strconv.Itoa(prID)
+}
+
+// parsePRActionValue reverses PRActionValue.
+func parsePRActionValue(v string) (repoSlug string, prID int, ok bool) {
+	repoSlug, id, found := strings.Cut(v, "#")
+	prID, err := strconv.Atoi(id)
+	return repoSlug, prID, found && err == nil && strings.Contains(repoSlug, "/")
+}
+
+// handlePRAction approves or requests changes on a PR as the Slack user who clicked
+// the card button, using the Bitbucket account they linked with /login. The card
+// itself is updated by the webhook Bitbucket sends back; the user gets an ephemeral
+// confirmation or error.
+func (h *Handler) handlePRAction(payload slack.InteractionCallback, action *slack.BlockAction) {
+	reply := func(text string) {
+		h.postToResponseURL(payload.ResponseURL, interactionReply{Text: text})
+	}
+
+	repoSlug, prID, ok := parsePRActionValue(action.Value)
+	if !ok {
+		h.log.Warn("invalid PR action value", "value", action.Value)
+		return
+	}
+	workspace, name, _ := strings.Cut(repoSlug, "/")
+
+	ctx := context.Background()
+	git, err := h.userProviderFor(ctx, payload.Team.ID, payload.User.ID, workspace)
+	if err != nil {
+		h.log.Error("bitbucket user provider", "user", payload.User.ID, "err", err)
+		reply(":x: Failed to reach Bitbucket")
+		return
+	}
+	if git == nil {
+		reply(":link: To review from Slack, link your Bitbucket account first: run `/login` in a direct message with the bot.")
+		return
+	}
+
+	verb := "approved"
+	if action.ActionID == ActionPRApprove {
+		err = git.Approve(ctx, name, prID)
+	} else {
+		verb = "requested changes on"
+		err = git.RequestChanges(ctx, name, prID)
+	}
+	if err != nil {
+		h.log.Error("review PR from slack", "repo", repoSlug, "pr", prID, "action", action.ActionID, "user", payload.User.ID, "err", err)
+		reply(fmt.Sprintf(":x: Bitbucket rejected the review on `%s` #%d%s", repoSlug, prID, userProviderErrorHint(err)))
+		return
+	}
+	reply(fmt.Sprintf(":white_check_mark: You %s `%s` #%d.", verb, repoSlug, prID))
+}
+
+// userProviderErrorHint is providerErrorHint for calls made with a user's own token,
+// which /login renews rather than /repo connect.
+func userProviderErrorHint(err error) string {
+	if errors.Is(err, provider.ErrUnauthorized) {
+		return " (Bitbucket rejected your token, run `/login` again)"
+	}
+	return providerErrorHint(err)
+}
@@ -0,0 +1,284 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitbucket-slack-bot/internal/provider"
+	"bitbucket-slack-bot/internal/store"
+
+	"github.com/slack-go/slack"
+)
+
+// prsPageSize is the number of pull requests shown per page of /repo prs.
+const prsPageSize = 10
+
+// openPR is one row of the /repo prs listing.
+type openPR struct {
+	repoSlug string
+	pr       provider.PullRequest
+}
+
+// handleRepoPRs implements /repo prs [workspace/repo]: it lists open PRs for one repo,
+// or for every repo subscribed in the channel, and replies via responseURL.
+func (h *Handler) handleRepoPRs(teamID, channelID, repoArg, responseURL string, page int, replace bool) {
+	reply := func(text string, blocks []slack.Block) {
+		h.postToResponseURL(responseURL, interactionReply{ReplaceOriginal: replace, Text: text, Blocks: blocks})
+	}
+
+	git, err := h.gitFor(teamID)
+	if err != nil {
+		h.log.Error("bitbucket provider", "team", teamID, "err", err)
+		reply(":x: Failed to reach Bitbucket", nil)
+		return
+	}
+	if git == nil {
+		reply(":warning: Bitbucket is not connected yet. Run `/repo connect <workspace>` to get started.", nil)
+		return
+	}
+
+	ctx := context.Background()
+	var repos []string
+	if repoArg != "" {
+		repos = []string{normalizeRepoSlug(repoArg)}
+	} else {
+		repos, err = h.repoStore.ListForChannel(ctx, channelID)
+		if err != nil {
+			reply(":x: Failed to fetch subscriptions", nil)
+			return
+		}
+		if len(repos) == 0 {
+			reply("No repositories subscribed in this channel. Use `/repo prs <workspace/repo>` or `/repo add` first.", nil)
+			return
+		}
+	}
+	repos, err = expandWorkspaceSlugs(ctx, git, repos)
+	if err != nil {
+		h.log.Error("list workspace repos", "workspace", git.Workspace(), "err", err)
+		reply(":x: Failed to list repositories in workspace `"+git.Workspace()+"`"+providerErrorHint(err), nil)
+		return
+	}
+
+	var prs []openPR
+	var failed []string
+	for _, repoSlug := range repos {
+		workspace, name, _ := strings.Cut(repoSlug, "/")
+		if workspace != git.Workspace() {
+			failed = append(failed, fmt.Sprintf("`%s` (not in connected workspace `%s`)", repoSlug, git.Workspace()))
+			continue
+		}
+		list, err := git.ListOpenPRs(ctx, name)
+		if err != nil {
+			h.log.Error("list open PRs", "repo", repoSlug, "err", err)
+			failed = append(failed, fmt.Sprintf("`%s`%s", repoSlug, providerErrorHint(err)))
+			continue
+		}
+		for _, pr := range list {
+			prs = append(prs, openPR{repoSlug: repoSlug, pr: pr})
+		}
+	}
+
+	// Oldest first: those are the ones most in need of attention.
+	sort.SliceStable(prs, func(i, j int) bool { return prs[i].pr.CreatedAt.Before(prs[j].pr.CreatedAt) })
+
+	reply("Open pull requests", h.buildPRListBlocks(ctx, teamID, prs, failed, repoArg, page))
+}
+
+// expandWorkspaceSlugs replaces workspace-wide slugs for the connected workspace ("acme/*")
+// with every repository in it, dropping duplicates. Other slugs are kept as given.
+func expandWorkspaceSlugs(ctx context.Context, git provider.Provider, slugs []string) ([]string, error) {
+	var all []provider.Repository
+	seen := make(map[string]bool)
+	var out []string
+	add := func(slug string) {
+		if !seen[slug] {
+			seen[slug] = true
+			out = append(out, slug)
+		}
+	}
+	for _, slug := range slugs {
+		workspace, _, _ := strings.Cut(slug, "/")
+		if !store.IsWorkspaceSlug(slug) || !strings.EqualFold(workspace, git.Workspace()) {
+			add(slug)
+			continue
+		}
+		if all == nil {
+			repos, err := git.ListRepos(ctx)
+			if err != nil {
+				return nil, err
+			}
+			all = repos
+		}
+		for _, r := range all {
+			add(r.FullName)
+		}
+	}
+	return out, nil
+}
+
+// buildPRListBlocks renders one page of open PRs with Previous/Next buttons.
+func (h *Handler) buildPRListBlocks(ctx context.Context, teamID string, prs []openPR, failed []string, repoArg string, page int) []slack.Block {
+	pages := max(1, (len(prs)+prsPageSize-1)/prsPageSize)
+	page = min(max(page, 0), pages-1)
+
+	header := fmt.Sprintf("*Open pull requests (%d)*", len(prs))
+	if pages > 1 {
+		header += fmt.Sprintf(" — page %d of %d", page+1, pages)
+	}
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, header, false, false), nil, nil),
+		slack.NewDividerBlock(),
+	}
+
+	if len(prs) == 0 {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "No open pull requests :tada:", false, false), nil, nil))
+	}
+
+	end := min((page+1)*prsPageSize, len(prs))
+	for _, item := range prs[page*prsPageSize : end] {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, h.formatOpenPR(ctx, teamID, item), false, false), nil, nil))
+	}
+
+	if len(failed) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, ":warning: Could not load "+strings.Join(failed, ", "), false, false)))
+	}
+
+	if pages > 1 {
+		var buttons []slack.BlockElement
+		if page > 0 {
+			buttons = append(buttons, slack.NewButtonBlockElement("repo_prs_page", prsPageValue(page-1, repoArg),
+				slack.NewTextBlockObject(slack.PlainTextType, "← Previous", false, false)))
+		}
+		if page < pages-1 {
+			buttons = append(buttons, slack.NewButtonBlockElement("repo_prs_page", prsPageValue(page+1, repoArg),
+				slack.NewTextBlockObject(slack.PlainTextType, "Next →", false, false)))
+		}
+		blocks = append(blocks, slack.NewActionBlock("repo_prs_pager", buttons...))
+	}
+
+	return blocks
+}
+
+// formatOpenPR renders one PR as mrkdwn: title link, age, author, reviewers,
+// approval count and the latest build state recorded for its head commit.
+func (h *Handler) formatOpenPR(ctx context.Context, teamID string, item openPR) string {
+	pr := item.pr
+	reviewers := pr.Reviewers
+	build := "—"
+
+	rec, err := h.repoStore.GetPRCommit(ctx, item.repoSlug, pr.ID)
+	if err != nil {
+		h.log.Warn("get PR commit", "repo", item.repoSlug, "pr", pr.ID, "err", err)
+	}
+	if rec != nil {
+		if len(reviewers) == 0 {
+			reviewers = rec.ReviewerNames
+		}
+		if bs, err := h.repoStore.GetBuildStatus(ctx, item.repoSlug, rec.CommitHash); err == nil && bs != nil {
+			build = buildStateLabel(bs.State)
+		}
+	}
+
+	approvals, err := h.repoStore.GetApprovals(ctx, item.repoSlug, pr.ID)
+	if err != nil {
+		h.log.Warn("get approvals", "repo", item.repoSlug, "pr", pr.ID, "err", err)
+	}
+
+	reviewerLabel := "—"
+	if len(reviewers) > 0 {
+		labels := make([]string, len(reviewers))
+		for i, r := range reviewers {
+			labels[i] = h.mention(ctx, teamID, r)
+		}
+		reviewerLabel = strings.Join(labels, ", ")
+	}
+
+	return fmt.Sprintf("*<%s|#%d %s>*  `%s`\nOpened %s ago by %s · Reviewers: %s\n:white_check_mark: %d approval%s · Build: %s",
+		pr.URL, pr.ID, pr.Title, item.repoSlug,
+		formatAge(time.Since(pr.CreatedAt)), h.mention(ctx, teamID, pr.Author), reviewerLabel,
+		len(approvals), plural(len(approvals)), build,
+	)
+}
+
+// mention returns "<@USERID>" for a Bitbucket display name linked in teamID, or
+// "*DisplayName*" otherwise.
+func (h *Handler) mention(ctx context.Context, teamID, displayName string) string {
+	id, err := h.repoStore.GetSlackUserByBitbucket(ctx, teamID, displayName)
+	if err != nil {
+		h.log.Warn("resolve user", "bitbucket", displayName, "err", err)
+	}
+	if id != "" {
+		return "<@" + id + ">"
+	}
+	return "*" + displayName + "*"
+}
+
+// buildStateLabel maps a Bitbucket build state to a short emoji label.
+func buildStateLabel(state string) string {
+	switch strings.ToUpper(state) {
+	case "INPROGRESS":
+		return ":hourglass_flowing_sand: running"
+	case "SUCCESSFUL":
+		return ":white_check_mark: passed"
+	case "FAILED":
+		return ":x: failed"
+	case "STOPPED":
+		return ":octagonal_sign: stopped"
+	}
+	return ":grey_question: " + strings.ToLower(state)
+}
+
+// formatAge renders a duration as a compact age such as "45m", "5h" or "3d".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// prsPageValue encodes a page number and the optional repo argument into a button value.
+func prsPageValue(page int, repoArg string) string {
+	return strconv.Itoa(page) + "|" + repoArg
+}
+
+// parsePRsPageValue reverses prsPageValue.
+func parsePRsPageValue(v string) (page int, repoArg string) {
+	p, repoArg, _ := strings.Cut(v, "|")
+	page, _ = strconv.Atoi(p)
+	return page, repoArg
+}
+
+// providerErrorHint turns a typed provider error into a short parenthetical
+// for the user; unknown errors get none.
+func providerErrorHint(err error) string {
+	var rl *provider.RateLimitError
+	switch {
+	case errors.Is(err, provider.ErrNotFound):
+		return " (not found)"
+	case errors.Is(err, provider.ErrUnauthorized):
+		return " (Bitbucket token rejected, run `/repo connect`)"
+	case errors.Is(err, provider.ErrForbidden):
+		return " (no access)"
+	case errors.As(err, &rl):
+		return " (rate limited by Bitbucket, try again shortly)"
+	}
+	return ""
+}
@@ -0,0 +1,318 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitbucket-slack-bot/internal/scheduler"
+	"bitbucket-slack-bot/internal/store"
+
+	"github.com/slack-go/slack"
+)
+
+// defaultReminderTiers are used when /repo remind set is given no after=.
+var defaultReminderTiers = []time.Duration{24 * time.Hour, 48 * time.Hour, 96 * time.Hour}
+
+const remindUsage = "Usage: `/repo remind set <workspace/repo|workspace/*> [after=24h,2d,4d] [quiet=19:00-08:00] [tz=Area/City]`, `/repo remind show`, `/repo remind off <workspace/repo|workspace/*>`"
+
+// Reminders nudges reviewers about open PRs that have gone without an approval
+// for longer than their repository's reminder policy allows.
+type Reminders struct {
+	outbox    *Outbox
+	repoStore *store.RepoStore
+	log       *slog.Logger
+}
+
+func NewReminders(outbox *Outbox, repoStore *store.RepoStore, log *slog.Logger) *Reminders {
+	return &Reminders{outbox: outbox, repoStore: repoStore, log: log}
+}
+
+// Run sends every reminder that is due at now. Each PR escalates through its
+// policy's tiers: every tier replies in the card thread and DMs the reviewers
+// who have not responded, and the last of several tiers also notifies the channel.
+// It is registered with the scheduler and safe to run on several instances at once.
+func (r *Reminders) Run(ctx context.Context, now time.Time) {
+	policies, err := r.repoStore.ListReminderPolicies(ctx, "")
+	if err != nil {
+		if ctx.Err() == nil {
+			r.log.Error("list reminder policies", "err", err)
+		}
+		return
+	}
+	if len(policies) == 0 {
+		return
+	}
+
+	byKey := make(map[[2]string]store.ReminderPolicy, len(policies))
+	var slugs []string
+	for _, p := range policies {
+		byKey[[2]string{p.TeamID, p.RepoSlug}] = p
+		if !slices.Contains(slugs, p.RepoSlug) {
+			slugs = append(slugs, p.RepoSlug)
+		}
+	}
+
+	prs, err := r.repoStore.ListOpenPRs(ctx, slugs)
+	if err != nil {
+		r.log.Error("list open PRs for reminders", "err", err)
+		return
+	}
+	for _, pr := range prs {
+		if len(pr.Approvals) > 0 {
+			continue
+		}
+		msgs, err := r.repoStore.GetPRMessages(ctx, pr.RepoSlug, pr.PRID)
+		if err != nil {
+			r.log.Error("get PR messages", "repo", pr.RepoSlug, "pr", pr.PRID, "err", err)
+			continue
+		}
+
+		byTeam := make(map[string][]store.PRMessage)
+		for _, m := range msgs {
+			byTeam[m.TeamID] = append(byTeam[m.TeamID], m)
+		}
+		workspace, _, _ := strings.Cut(pr.RepoSlug, "/")
+		for teamID, cards := range byTeam {
+			policy, ok := byKey[[2]string{teamID, pr.RepoSlug}]
+			if !ok {
+				policy, ok = byKey[[2]string{teamID, store.WorkspaceSlug(workspace)}]
+			}
+			if !ok || policy.Quiet(now) {
+				continue
+			}
+			tier := policy.DueTier(pr.CreatedAt, now)
+			if tier == 0 {
+				continue
+			}
+			advanced, err := r.repoStore.AdvancePRReminder(ctx, teamID, pr.RepoSlug, pr.PRID, tier)
+			if err != nil {
+				r.log.Error("advance PR reminder", "repo", pr.RepoSlug, "pr", pr.PRID, "err", err)
+				continue
+			}
+			if advanced {
+				r.remind(ctx, teamID, cards, pr, now, tier > 1 && tier == len(policy.Tiers))
+			}
+		}
+	}
+}
+
+// remind replies under each of the team's cards for pr and DMs its unresponsive
+// reviewers. escalate adds an @here to the thread reply.
+func (r *Reminders) remind(ctx context.Context, teamID string, cards []store.PRMessage, pr store.OpenPR, now time.Time, escalate bool) {
+	// Reviewers who requested changes have responded; the PR is waiting on its author for them.
+	var pending []string
+	for _, name := range pr.ReviewerNames {
+		if !slices.Contains(pr.ChangeRequests, name) {
+			pending = append(pending, name)
+		}
+	}
+
+	age := formatAge(now.Sub(pr.CreatedAt))
+	text := fmt.Sprintf(":alarm_clock: This pull request has been waiting %s without an approval.", age)
+	if len(pending) > 0 {
+		names := make([]string, len(pending))
+		for i, name := range pending {
+			names[i] = r.mention(ctx, teamID, name)
+		}
+		text += " " + strings.Join(names, ", ") + ", please take a look."
+	} else {
+		text += " It has no reviewers waiting to respond."
+	}
+	if escalate {
+		text = "<!here> " + text
+	}
+	for _, card := range cards {
+		if err := r.outbox.Reply(ctx, teamID, card.ChannelID, pr.RepoSlug, pr.PRID, text); err != nil {
+			r.log.Error("queue reminder reply", "repo", pr.RepoSlug, "pr", pr.PRID, "channel", card.ChannelID, "err", err)
+		}
+	}
+
+	// The outbox links each DM to the team's card thread.
+	dm := fmt.Sprintf(":alarm_clock: *%s* is waiting for your review on <%s|#%d %s> in `%s` (open %s).",
+		pr.AuthorName, pr.URL, pr.PRID, pr.Title, pr.RepoSlug, age)
+	for _, name := range pending {
+		userID, err := r.repoStore.GetSlackUserByBitbucket(ctx, teamID, name)
+		if err != nil || userID == "" {
+			continue
+		}
+		if err := r.outbox.DM(ctx, teamID, userID, pr.RepoSlug, pr.PRID, dm); err != nil {
+			r.log.Error("queue review reminder", "user", userID, "err", err)
+		}
+	}
+}
+
+// mention returns "<@USERID>" for a Bitbucket display name linked in teamID, or
+// "*DisplayName*" otherwise.
+func (r *Reminders) mention(ctx context.Context, teamID, displayName string) string {
+	if id, err := r.repoStore.GetSlackUserByBitbucket(ctx, teamID, displayName); err == nil && id != "" {
+		return "<@" + id + ">"
+	}
+	return "*" + displayName + "*"
+}
+
+// handleRepoRemind implements /repo remind: set, show or remove the team's review
+// reminder policies. Replies go to cmd.ResponseURL.
+func (h *Handler) handleRepoRemind(cmd slack.SlashCommand, args []string) {
+	reply := func(text string) {
+		h.postToResponseURL(cmd.ResponseURL, interactionReply{Text: text})
+	}
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		reply(remindUsage)
+		return
+	}
+	switch args[0] {
+	case "set":
+		p, err := h.parseReminderPolicy(ctx, cmd, args[1:])
+		if err != nil {
+			reply(":warning: " + err.Error() + "\n" + remindUsage)
+			return
+		}
+		if err := h.repoStore.SaveReminderPolicy(ctx, *p); err != nil {
+			h.log.Error("save reminder policy", "repo", p.RepoSlug, "err", err)
+			reply(":x: Failed to save the reminder policy")
+			return
+		}
+		reply(":white_check_mark: " + describeReminderPolicy(*p))
+	case "show":
+		policies, err := h.repoStore.ListReminderPolicies(ctx, cmd.TeamID)
+		if err != nil {
+			h.log.Error("list reminder policies", "team", cmd.TeamID, "err", err)
+			reply(":x: Failed to load reminder policies")
+			return
+		}
+		if len(policies) == 0 {
+			reply("No review reminders are set. Add one with `/repo remind set <workspace/repo>`.")
+			return
+		}
+		lines := make([]string, len(policies))
+		for i, p := range policies {
+			lines[i] = "• " + describeReminderPolicy(p)
+		}
+		reply("*Review reminders*\n" + strings.Join(lines, "\n"))
+	case "off":
+		if len(args) < 2 {
+			reply(remindUsage)
+			return
+		}
+		slug := normalizeRepoSlug(args[1])
+		found, err := h.repoStore.DeleteReminderPolicy(ctx, cmd.TeamID, slug)
+		if err != nil {
+			h.log.Error("delete reminder policy", "repo", slug, "err", err)
+			reply(":x: Failed to remove the reminder policy")
+			return
+		}
+		if !found {
+			reply(fmt.Sprintf("No review reminders are set for `%s`.", slug))
+			return
+		}
+		reply(fmt.Sprintf(":white_check_mark: Review reminders turned off for `%s`.", slug))
+	default:
+		reply(remindUsage)
+	}
+}
+
+// parseReminderPolicy parses the arguments of /repo remind set. Without tz= the
+// invoking user's Slack time zone is used for quiet hours.
+func (h *Handler) parseReminderPolicy(ctx context.Context, cmd slack.SlashCommand, args []string) (*store.ReminderPolicy, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("a repository is required")
+	}
+	p := &store.ReminderPolicy{
+		TeamID:   cmd.TeamID,
+		RepoSlug: normalizeRepoSlug(args[0]),
+		Tiers:    defaultReminderTiers,
+	}
+	if !strings.Contains(p.RepoSlug, "/") {
+		return nil, fmt.Errorf("expected workspace/repo or workspace/*, got %q", args[0])
+	}
+
+	var tz string
+	for _, arg := range args[1:] {
+		key, value, _ := strings.Cut(arg, "=")
+		switch key {
+		case "after":
+			p.Tiers = nil
+			for _, s := range strings.Split(value, ",") {
+				d, err := parseReminderAge(s)
+				if err != nil {
+					return nil, err
+				}
+				p.Tiers = append(p.Tiers, d)
+			}
+			slices.Sort(p.Tiers)
+			p.Tiers = slices.Compact(p.Tiers)
+		case "quiet":
+			if value == "off" {
+				p.QuietStart, p.QuietEnd = 0, 0
+				continue
+			}
+			from, to, ok := strings.Cut(value, "-")
+			start, err1 := scheduler.ParseClock(from)
+			end, err2 := scheduler.ParseClock(to)
+			if !ok || err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("quiet hours must look like 19:00-08:00")
+			}
+			p.QuietStart, p.QuietEnd = start, end
+		case "tz":
+			tz = value
+		default:
+			return nil, fmt.Errorf("unknown option %q", arg)
+		}
+	}
+	if tz == "" {
+		tz = h.userTimezone(ctx, cmd.TeamID, cmd.UserID)
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", tz)
+	}
+	p.Timezone = loc.String()
+	return p, nil
+}
+
+// parseReminderAge parses a reminder tier such as "90m", "24h" or "2d".
+func parseReminderAge(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("invalid reminder age %q, expected e.g. 24h or 2d", s)
+	}
+	return d, nil
+}
+
+// formatReminderAge is the inverse of parseReminderAge.
+func formatReminderAge(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
+
+func describeReminderPolicy(p store.ReminderPolicy) string {
+	tiers := make([]string, len(p.Tiers))
+	for i, t := range p.Tiers {
+		tiers[i] = formatReminderAge(t)
+	}
+	s := fmt.Sprintf("`%s`: remind after %s without an approval", p.RepoSlug, strings.Join(tiers, ", "))
+	if p.QuietStart != p.QuietEnd {
+		s += fmt.Sprintf(", quiet %s–%s (%s)", scheduler.FormatClock(p.QuietStart), scheduler.FormatClock(p.QuietEnd), p.Timezone)
+	}
+	return s
+}
@@ -0,0 +1,275 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"bitbucket-slack-bot/internal/provider"
+	"bitbucket-slack-bot/internal/store"
+
+	"github.com/slack-go/slack"
+)
+
+// maxRepoSuggestions caps how many close matches /repo add offers for an unknown repo.
+const maxRepoSuggestions = 5
+
+// repoAddUsage documents /repo add and its filter flags.
+const repoAddUsage = "Usage: `/repo add <workspace/repo | workspace/*> [--events created,merged,...] [--target main,release/*,...] [--source feature/*,...] [--paths services/billing/**,...] [--exclude-author name,...]`"
+
+// handleRepoAdd implements /repo add <workspace/repo> [filters] and its workspace-wide
+// form /repo add <workspace>/* [filters]: it checks the repository exists and
+// is readable with the team's token, subscribes the channel and registers (or updates) the
+// repository webhook, replying via responseURL. Unknown repos get close matches as buttons.
+// When the webhook cannot be registered automatically it falls back to manual instructions.
+// args holds the repository followed by any filter flags.
+func (h *Handler) handleRepoAdd(teamID, channelID string, args []string, responseURL string, replace bool) {
+	reply := func(text string, blocks []slack.Block) {
+		h.postToResponseURL(responseURL, interactionReply{ReplaceOriginal: replace, Text: text, Blocks: blocks})
+	}
+	if len(args) == 0 {
+		reply(repoAddUsage, nil)
+		return
+	}
+	repoSlug := normalizeRepoSlug(args[0])
+	filterArgs := args[1:]
+	filter, err := parseSubscriptionFilter(filterArgs)
+	if err != nil {
+		reply(fmt.Sprintf(":x: %s\n%s", err, repoAddUsage), nil)
+		return
+	}
+	ctx := context.Background()
+
+	rec, err := h.repoStore.GetToken(ctx, teamID)
+	if err != nil {
+		reply(":x: Failed to check connection status", nil)
+		return
+	}
+	if rec == nil {
+		reply(":warning: Bitbucket is not connected yet. Run `/repo connect <workspace>` to get started.", nil)
+		return
+	}
+	if rec.Disconnected {
+		reply(fmt.Sprintf(":warning: The connection to Bitbucket workspace `%s` has expired. Run `/repo connect %s` to reconnect.", rec.Workspace, rec.Workspace), nil)
+		return
+	}
+
+	workspace, name, ok := strings.Cut(repoSlug, "/")
+	if !ok || name == "" {
+		reply(repoAddUsage, nil)
+		return
+	}
+	if !strings.EqualFold(workspace, rec.Workspace) {
+		reply(fmt.Sprintf(":x: `%s` is not in the connected Bitbucket workspace `%s`.", repoSlug, rec.Workspace), nil)
+		return
+	}
+
+	git, err := h.gitFor(teamID)
+	if err != nil || git == nil {
+		h.log.Error("bitbucket provider", "team", teamID, "err", err)
+		reply(":x: Failed to reach Bitbucket", nil)
+		return
+	}
+
+	target := fmt.Sprintf("`%s`", repoSlug)
+	if name == "*" {
+		// Workspace-wide: covers every repository, including ones created later.
+		slug, err := workspaceSubscriptionSlug(ctx, git)
+		switch {
+		case errors.Is(err, provider.ErrNotFound), errors.Is(err, provider.ErrForbidden):
+			reply(fmt.Sprintf(":x: Workspace `%s` was not found, or the connected Bitbucket account cannot access it.", git.Workspace()), nil)
+			return
+		case err != nil:
+			h.log.Error("get workspace", "workspace", git.Workspace(), "err", err)
+			reply(fmt.Sprintf(":x: Failed to look up workspace `%s`%s", git.Workspace(), providerErrorHint(err)), nil)
+			return
+		}
+		repoSlug = slug
+		target = fmt.Sprintf("every repository in workspace `%s`", strings.TrimSuffix(slug, "/*"))
+	} else {
+		repo, err := git.GetRepo(ctx, name)
+		switch {
+		case errors.Is(err, provider.ErrNotFound), errors.Is(err, provider.ErrForbidden):
+			h.replyRepoNotFound(ctx, git, repoSlug, filterArgs, reply)
+			return
+		case err != nil:
+			h.log.Error("get repo", "repo", repoSlug, "err", err)
+			reply(fmt.Sprintf(":x: Failed to look up `%s`%s", repoSlug, providerErrorHint(err)), nil)
+			return
+		}
+		// Use Bitbucket's spelling so the slug matches repository.full_name in webhooks.
+		repoSlug = repo.FullName
+		target = fmt.Sprintf("`%s`", repoSlug)
+	}
+
+	if err := h.repoStore.Subscribe(ctx, channelID, teamID, repoSlug, filter); err != nil {
+		h.log.Error("subscribe repo", "repo", repoSlug, "err", err)
+		reply(fmt.Sprintf(":x: Failed to subscribe to `%s`", repoSlug), nil)
+		return
+	}
+
+	secret, err := h.repoStore.GetOrCreateWebhookSecret(ctx, repoSlug)
+	if err != nil {
+		h.log.Error("get webhook secret", "repo", repoSlug, "err", err)
+		reply(":x: Failed to generate webhook secret", nil)
+		return
+	}
+
+	subscribed := fmt.Sprintf(":white_check_mark: This channel will now receive PR notifications for %s.", target)
+	if !filter.IsZero() {
+		subscribed += fmt.Sprintf("\nFilters: %s", filter)
+	}
+	if err := h.ensureWebhook(ctx, git, repoSlug, secret); err != nil {
+		h.log.Error("register webhook", "repo", repoSlug, "err", err)
+		reply(subscribed+"\n\n"+manualWebhookInstructions(repoSlug, h.webhookURL(), secret, err), nil)
+		return
+	}
+	reply(subscribed+"\nThe Bitbucket webhook is registered, no further setup needed.", nil)
+}
+
+// workspaceSubscriptionSlug returns the workspace-wide subscription slug for git's
+// workspace. The workspace is stored as typed at /repo connect, so it is looked up to
+// use Bitbucket's spelling, which is what repository.full_name in webhooks carries.
+func workspaceSubscriptionSlug(ctx context.Context, git provider.Provider) (string, error) {
+	ws, err := git.GetWorkspace(ctx)
+	if err != nil {
+		return "", err
+	}
+	return store.WorkspaceSlug(ws.Slug), nil
+}
+
+// replyRepoNotFound tells the user repoSlug does not exist (or is not visible to the
+// connected account) and offers the closest repository names as add buttons that
+// carry the original filter flags.
+func (h *Handler) replyRepoNotFound(ctx context.Context, git provider.Provider, repoSlug string, filterArgs []string, reply func(string, []slack.Block)) {
+	text := fmt.Sprintf(":x: Repository `%s` was not found, or the connected Bitbucket account cannot access it.", repoSlug)
+
+	repos, err := git.ListRepos(ctx)
+	if err != nil {
+		h.log.Error("list repos for suggestions", "workspace", git.Workspace(), "err", err)
+		reply(text, nil)
+		return
+	}
+	_, name, _ := strings.Cut(repoSlug, "/")
+	matches := closeRepoMatches(name, repos, maxRepoSuggestions)
+	if len(matches) == 0 {
+		reply(text, nil)
+		return
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text+"\nDid you mean:", false, false), nil, nil),
+	}
+	var buttons []slack.BlockElement
+	for _, r := range matches {
+		value := strings.Join(append([]string{r.FullName}, filterArgs...), " ")
+		buttons = append(buttons, slack.NewButtonBlockElement("repo_add", value,
+			slack.NewTextBlockObject(slack.PlainTextType, r.FullName, false, false)))
+	}
+	blocks = append(blocks, slack.NewActionBlock("repo_add_suggestions", buttons...))
+	reply(text, blocks)
+}
+
+// parseSubscriptionFilter parses /repo add filter flags. Each flag takes a
+// comma-separated list and may be repeated; branch and path flags take glob patterns.
+func parseSubscriptionFilter(args []string) (store.SubscriptionFilter, error) {
+	var f store.SubscriptionFilter
+	for i := 0; i < len(args); i++ {
+		flag, value, hasValue := strings.Cut(args[i], "=")
+		if !hasValue {
+			if i+1 >= len(args) {
+				return f, fmt.Errorf("missing value for `%s`", flag)
+			}
+			i++
+			value = args[i]
+		}
+		values := splitList(value)
+		switch flag {
+		case "--events":
+			for _, ev := range values {
+				if !slices.Contains(store.FilterEvents, ev) {
+					return f, fmt.Errorf("unknown event `%s` (valid: %s)", ev, strings.Join(store.FilterEvents, ", "))
+				}
+			}
+			f.Events = append(f.Events, values...)
+		case "--target":
+			f.TargetBranches = append(f.TargetBranches, values...)
+		case "--source":
+			f.SourceBranches = append(f.SourceBranches, values...)
+		case "--paths":
+			f.Paths = append(f.Paths, values...)
+		case "--exclude-author":
+			f.ExcludeAuthors = append(f.ExcludeAuthors, values...)
+		default:
+			return f, fmt.Errorf("unknown option `%s`", flag)
+		}
+	}
+	return f, nil
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// closeRepoMatches returns up to limit repositories whose slug is close to name:
+// within a small edit distance, or containing (or contained in) it. Closest first.
+func closeRepoMatches(name string, repos []provider.Repository, limit int) []provider.Repository {
+	name = strings.ToLower(name)
+	maxDist := max(2, len(name)/3)
+
+	type scored struct {
+		repo provider.Repository
+		dist int
+	}
+	var candidates []scored
+	for _, r := range repos {
+		slug := strings.ToLower(r.Slug)
+		dist := levenshtein(name, slug)
+		if dist > maxDist && !strings.Contains(slug, name) && !strings.Contains(name, slug) {
+			continue
+		}
+		candidates = append(candidates, scored{r, dist})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+
+	var out []provider.Repository
+	for _, c := range candidates {
+		if len(out) == limit {
+			break
+		}
+		out = append(out, c.repo)
+	}
+	return out
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
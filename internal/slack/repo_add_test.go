@@ -0,0 +1,51 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"bitbucket-slack-bot/internal/provider"
+)
+
+// fakeWorkspaceProvider answers GetWorkspace as Bitbucket does for a workspace
+// connected under a different spelling. Any other method panics.
+type fakeWorkspaceProvider struct {
+	provider.Provider
+	typed string
+	err   error
+}
+
+func (p fakeWorkspaceProvider) Workspace() string { return p.typed }
+
+func (p fakeWorkspaceProvider) GetWorkspace(context.Context) (*provider.Workspace, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &provider.Workspace{Slug: strings.ToLower(p.typed), Name: p.typed + " Corp"}, nil
+}
+
+func TestWorkspaceSubscriptionSlug(t *testing.T) {
+	tests := []struct {
+		name    string
+		git     fakeWorkspaceProvider
+		want    string
+		wantErr error
+	}{
+		{"canonical spelling", fakeWorkspaceProvider{typed: "acme"}, "acme/*", nil},
+		{"connected with capitals", fakeWorkspaceProvider{typed: "Acme"}, "acme/*", nil},
+		{"lookup fails", fakeWorkspaceProvider{typed: "Acme", err: provider.ErrForbidden}, "", provider.ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := workspaceSubscriptionSlug(context.Background(), tt.git)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("slug = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,19 +6,25 @@ import (
 	"net/http"
 	"strings"
 
-	"bitbucket-slack-bot/internal/store"
-
 	"github.com/gofiber/fiber/v2"
 	slacklib "github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 )
 
 // RegisterRoutes mounts all Slack webhook routes under the given router group.
-func RegisterRoutes(router fiber.Router, h *Handler, signingSecret string, refreshFn func(*store.TokenRecord) (*store.TokenRecord, error)) {
+// ih may be nil when the "Add to Slack" flow is not configured.
+func RegisterRoutes(router fiber.Router, h *Handler, ih *InstallHandler, signingSecret string) {
+	// Browser redirects carry no Slack signature, so they must be registered
+	// before the verified group claims the /slack prefix.
+	if ih != nil {
+		router.Get("/slack/oauth/install", ih.HandleInstall)
+		router.Get("/slack/oauth/callback", ih.HandleCallback)
+	}
+
 	verified := router.Group("/slack", VerifySignature(signingSecret))
 
 	verified.Post("/events", h.eventsRoute())
-	verified.Post("/commands", h.commandsRoute(refreshFn))
+	verified.Post("/commands", h.commandsRoute())
 	verified.Post("/interactions", h.interactionsRoute())
 }
 
@@ -51,7 +57,7 @@ func (h *Handler) eventsRoute() fiber.Handler {
 	}
 }
 
-func (h *Handler) commandsRoute(refreshFn func(*store.TokenRecord) (*store.TokenRecord, error)) fiber.Handler {
+func (h *Handler) commandsRoute() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(c.Body()))
 		if err != nil {
@@ -69,14 +75,17 @@ func (h *Handler) commandsRoute(refreshFn func(*store.TokenRecord) (*store.Token
 		if cmd.Command == "/login" {
 			return c.JSON(h.loginResponse(cmd))
 		}
+		if cmd.Command == "/notify" {
+			return c.JSON(h.notifyResponse(cmd))
+		}
 		if cmd.Command == "/repo" {
 			sub := strings.Fields(cmd.Text)
-			if len(sub) > 0 && (sub[0] == "connect" || sub[0] == "add" || sub[0] == "list" || sub[0] == "delete") {
+			if len(sub) > 0 && (sub[0] == "connect" || sub[0] == "list" || sub[0] == "delete") {
 				return c.JSON(h.repoSubResponse(cmd))
 			}
 		}
 
-		go h.HandleSlashCommand(cmd, refreshFn)
+		go h.HandleSlashCommand(cmd)
 
 		// Empty JSON object: Slack silently acks without showing any message.
 		return c.JSON(fiber.Map{})
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DigestSchedule is a channel's recurring open-PR digest.
+type DigestSchedule struct {
+	ChannelID  string
+	TeamID     string
+	Weekdays   []time.Weekday
+	AtMinute   int    // minutes after local midnight
+	Timezone   string // IANA name, e.g. "Europe/Berlin"
+	StaleDays  int
+	NextRunAt  time.Time
+	LastSentAt *time.Time
+}
+
+const digestColumns = `channel_id, team_id, weekdays, at_minute, timezone, stale_days, next_run_at, last_sent_at`
+
+func scanDigest(row pgx.Row) (*DigestSchedule, error) {
+	var d DigestSchedule
+	var days []int32
+	if err := row.Scan(&d.ChannelID, &d.TeamID, &days, &d.AtMinute, &d.Timezone, &d.StaleDays, &d.NextRunAt, &d.LastSentAt); err != nil {
+		return nil, err
+	}
+	for _, day := range days {
+		d.Weekdays = append(d.Weekdays, time.Weekday(day))
+	}
+	return &d, nil
+}
+
+// SaveDigestSchedule creates or replaces the digest schedule for d.ChannelID.
+func (s *RepoStore) SaveDigestSchedule(ctx context.Context, d DigestSchedule) error {
+	days := make([]int32, len(d.Weekdays))
+	for i, day := range d.Weekdays {
+		days[i] = int32(day)
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO digest_schedules (channel_id, team_id, weekdays, at_minute, timezone, stale_days, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (channel_id) DO UPDATE SET
+			team_id      = EXCLUDED.team_id,
+			weekdays     = EXCLUDED.weekdays,
+			at_minute    = EXCLUDED.at_minute,
+			timezone     = EXCLUDED.timezone,
+			stale_days   = EXCLUDED.stale_days,
+			next_run_at  = EXCLUDED.next_run_at,
+			locked_until = NULL
+	`, d.ChannelID, d.TeamID, days, d.AtMinute, d.Timezone, d.StaleDays, d.NextRunAt)
+	return err
+}
+
+// GetDigestSchedule returns the digest schedule for channelID, or nil if none is set.
+func (s *RepoStore) GetDigestSchedule(ctx context.Context, channelID string) (*DigestSchedule, error) {
+	d, err := scanDigest(s.pool.QueryRow(ctx,
+		`SELECT `+digestColumns+` FROM digest_schedules WHERE channel_id = $1`, channelID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return d, err
+}
+
+// DeleteDigestSchedule turns off the digest for channelID.
+func (s *RepoStore) DeleteDigestSchedule(ctx context.Context, channelID string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM digest_schedules WHERE channel_id = $1`, channelID)
+	return err
+}
+
+// ClaimDueDigests locks every digest whose next run is at or before now for lease and
+// returns them. A claimed digest is not returned again until the lease expires or it
+// is completed with CompleteDigest, so several bot instances never double-post.
+func (s *RepoStore) ClaimDueDigests(ctx context.Context, now time.Time, lease time.Duration) ([]DigestSchedule, error) {
+	rows, err := s.pool.Query(ctx, `
+		UPDATE digest_schedules SET locked_until = $2
+		WHERE next_run_at <= $1 AND (locked_until IS NULL OR locked_until < $1)
+		RETURNING `+digestColumns,
+		now, now.Add(lease),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DigestSchedule
+	for rows.Next() {
+		d, err := scanDigest(rows)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, *d)
+	}
+	return due, rows.Err()
+}
+
+// CompleteDigest releases a claimed digest and schedules its next run. sentAt is
+// recorded as the last delivery time unless it is zero (the run was skipped).
+func (s *RepoStore) CompleteDigest(ctx context.Context, channelID string, nextRunAt, sentAt time.Time) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE digest_schedules
+		SET next_run_at = $2, locked_until = NULL,
+		    last_sent_at = CASE WHEN $3::timestamptz IS NULL THEN last_sent_at ELSE $3 END
+		WHERE channel_id = $1
+	`, channelID, nextRunAt, nullTime(sentAt))
+	return err
+}
+
+// nullTime maps the zero time to SQL NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
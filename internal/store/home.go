@@ -0,0 +1,44 @@
+package store
+
+import "context"
+
+// HomeViewer is a Slack user whose App Home tab shows a linked Bitbucket account.
+type HomeViewer struct {
+	TeamID            string
+	SlackUserID       string
+	BitbucketUsername string
+}
+
+// SaveHomeView records that slackUserID has opened the App Home in teamID.
+func (s *RepoStore) SaveHomeView(ctx context.Context, slackUserID, teamID string) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO home_views (slack_user_id, team_id) VALUES ($1, $2)
+		ON CONFLICT (slack_user_id) DO UPDATE SET team_id = EXCLUDED.team_id, opened_at = NOW()
+	`, slackUserID, teamID)
+	return err
+}
+
+// HomeViewers returns the users of teamIDs linked to any of the given Bitbucket display
+// names who have opened the App Home.
+func (s *RepoStore) HomeViewers(ctx context.Context, teamIDs, bitbucketUsernames []string) ([]HomeViewer, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT v.team_id, v.slack_user_id, m.bitbucket_username
+		FROM home_views v
+		JOIN user_mappings m ON m.team_id = v.team_id AND m.slack_user_id = v.slack_user_id
+		WHERE v.team_id = ANY($1) AND m.bitbucket_username = ANY($2)
+	`, teamIDs, bitbucketUsernames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var viewers []HomeViewer
+	for rows.Next() {
+		var v HomeViewer
+		if err := rows.Scan(&v.TeamID, &v.SlackUserID, &v.BitbucketUsername); err != nil {
+			return nil, err
+		}
+		viewers = append(viewers, v)
+	}
+	return viewers, rows.Err()
+}
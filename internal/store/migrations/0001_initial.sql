@@ -0,0 +1,124 @@
+-- Initial schema. Every statement is idempotent so installs that predate
+-- versioned migrations (which ran this DDL on every start) adopt it cleanly.
+
+CREATE TABLE IF NOT EXISTS repo_subscriptions (
+	id         SERIAL PRIMARY KEY,
+	channel_id TEXT        NOT NULL,
+	team_id    TEXT        NOT NULL,
+	repo_slug  TEXT        NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE(channel_id, repo_slug)
+);
+
+CREATE TABLE IF NOT EXISTS bitbucket_tokens (
+	team_id       TEXT PRIMARY KEY,
+	workspace     TEXT        NOT NULL,
+	access_token  TEXT        NOT NULL,
+	refresh_token TEXT        NOT NULL,
+	expires_at    TIMESTAMPTZ NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS oauth_states (
+	nonce      TEXT PRIMARY KEY,
+	kind       TEXT        NOT NULL,
+	team_id    TEXT        NOT NULL DEFAULT '',
+	channel_id TEXT        NOT NULL,
+	user_id    TEXT        NOT NULL,
+	workspace  TEXT        NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_secrets (
+	repo_slug  TEXT PRIMARY KEY,
+	secret     TEXT        NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS pr_messages (
+	repo_slug   TEXT    NOT NULL,
+	pr_id       INTEGER NOT NULL,
+	channel_id  TEXT    NOT NULL,
+	message_ts  TEXT    NOT NULL,
+	PRIMARY KEY (repo_slug, pr_id, channel_id)
+);
+
+CREATE TABLE IF NOT EXISTS pr_approvals (
+	repo_slug   TEXT    NOT NULL,
+	pr_id       INTEGER NOT NULL,
+	user_name   TEXT    NOT NULL,
+	PRIMARY KEY (repo_slug, pr_id, user_name)
+);
+
+ALTER TABLE pr_messages ADD COLUMN IF NOT EXISTS team_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS slack_installations (
+	team_id      TEXT PRIMARY KEY,
+	team_name    TEXT        NOT NULL,
+	bot_user_id  TEXT        NOT NULL,
+	bot_token    TEXT        NOT NULL,
+	installed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS slack_outbox (
+	id              BIGSERIAL PRIMARY KEY,
+	team_id         TEXT        NOT NULL,
+	channel_id      TEXT        NOT NULL,
+	kind            TEXT        NOT NULL,
+	repo_slug       TEXT        NOT NULL DEFAULT '',
+	pr_id           INTEGER     NOT NULL DEFAULT 0,
+	text            TEXT        NOT NULL DEFAULT '',
+	blocks          JSONB,
+	attempts        INTEGER     NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	locked_until    TIMESTAMPTZ,
+	last_error      TEXT        NOT NULL DEFAULT '',
+	failed          BOOLEAN     NOT NULL DEFAULT FALSE,
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_slack_outbox_channel ON slack_outbox (channel_id, id) WHERE NOT failed;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	request_uuid TEXT PRIMARY KEY,
+	event_key    TEXT        NOT NULL,
+	hook_uuid    TEXT        NOT NULL DEFAULT '',
+	received_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_received ON webhook_deliveries (received_at);
+
+CREATE TABLE IF NOT EXISTS pr_change_requests (
+	repo_slug   TEXT    NOT NULL,
+	pr_id       INTEGER NOT NULL,
+	user_name   TEXT    NOT NULL,
+	PRIMARY KEY (repo_slug, pr_id, user_name)
+);
+
+CREATE TABLE IF NOT EXISTS user_mappings (
+	slack_user_id      TEXT PRIMARY KEY,
+	bitbucket_username TEXT NOT NULL UNIQUE,
+	created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS pr_commits (
+	repo_slug      TEXT    NOT NULL,
+	pr_id          INTEGER NOT NULL,
+	commit_hash    TEXT    NOT NULL,
+	pr_title       TEXT    NOT NULL,
+	pr_url         TEXT    NOT NULL,
+	author_name    TEXT    NOT NULL,
+	reviewer_names TEXT    NOT NULL DEFAULT '[]',
+	source_branch  TEXT    NOT NULL,
+	dest_branch    TEXT    NOT NULL,
+	PRIMARY KEY (repo_slug, pr_id)
+);
+CREATE INDEX IF NOT EXISTS idx_pr_commits_hash ON pr_commits (repo_slug, commit_hash);
+
+CREATE TABLE IF NOT EXISTS build_statuses (
+	repo_slug   TEXT        NOT NULL,
+	commit_hash TEXT        NOT NULL,
+	state       TEXT        NOT NULL,
+	name        TEXT        NOT NULL,
+	url         TEXT        NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (repo_slug, commit_hash)
+);
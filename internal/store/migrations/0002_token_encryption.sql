@@ -0,0 +1,5 @@
+-- Envelope encryption for Bitbucket OAuth tokens. key_id names the key-encryption
+-- key that wrapped data_key; rows with an empty key_id are still plaintext.
+ALTER TABLE bitbucket_tokens
+	ADD COLUMN key_id   TEXT NOT NULL DEFAULT '',
+	ADD COLUMN data_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Who connected each workspace (to DM them when it breaks) and when the
+-- background refresher gave up on its refresh token.
+ALTER TABLE bitbucket_tokens
+	ADD COLUMN connected_by    TEXT NOT NULL DEFAULT '',
+	ADD COLUMN disconnected_at TIMESTAMPTZ;
@@ -0,0 +1,6 @@
+-- Per-subscription notification filters. Empty arrays mean "no restriction",
+-- so existing subscriptions keep receiving everything.
+ALTER TABLE repo_subscriptions
+	ADD COLUMN events          TEXT[] NOT NULL DEFAULT '{}',
+	ADD COLUMN target_branches TEXT[] NOT NULL DEFAULT '{}',
+	ADD COLUMN exclude_authors TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+-- Source branch patterns for subscription filters. target_branches now holds
+-- glob patterns too; plain branch names remain valid patterns.
+ALTER TABLE repo_subscriptions
+	ADD COLUMN source_branches TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,8 @@
+-- Path globs for subscription filters, and the files each PR touches so
+-- follow-up events can be routed without refetching the diffstat.
+-- A NULL changed_paths means the files are unknown.
+ALTER TABLE repo_subscriptions
+	ADD COLUMN paths TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE pr_commits
+	ADD COLUMN changed_paths TEXT[];
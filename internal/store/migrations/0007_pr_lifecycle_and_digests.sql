@@ -0,0 +1,24 @@
+-- PR lifecycle columns so open PRs can be listed without calling Bitbucket.
+-- Rows that predate this migration get state 'UNKNOWN' until their next
+-- webhook event reports the real state; new rows default to 'OPEN'.
+ALTER TABLE pr_commits
+	ADD COLUMN state      TEXT        NOT NULL DEFAULT 'UNKNOWN',
+	ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE pr_commits ALTER COLUMN state SET DEFAULT 'OPEN';
+CREATE INDEX idx_pr_commits_state ON pr_commits (state);
+
+-- One open-PR digest schedule per channel. weekdays uses time.Weekday
+-- numbering (0 = Sunday); at_minute is minutes after local midnight.
+CREATE TABLE digest_schedules (
+	channel_id   TEXT PRIMARY KEY,
+	team_id      TEXT        NOT NULL,
+	weekdays     INTEGER[]   NOT NULL,
+	at_minute    INTEGER     NOT NULL,
+	timezone     TEXT        NOT NULL,
+	stale_days   INTEGER     NOT NULL,
+	next_run_at  TIMESTAMPTZ NOT NULL,
+	locked_until TIMESTAMPTZ,
+	last_sent_at TIMESTAMPTZ
+);
+CREATE INDEX idx_digest_schedules_next_run ON digest_schedules (next_run_at);
@@ -0,0 +1,23 @@
+-- Review reminder policy per team and repository. repo_slug may be a
+-- workspace slug ("acme/*") that applies to every repository without its own
+-- policy. tier_minutes are PR ages, ascending; quiet_start/quiet_end are
+-- minutes after local midnight, and equal values mean no quiet hours.
+CREATE TABLE reminder_policies (
+	team_id      TEXT      NOT NULL,
+	repo_slug    TEXT      NOT NULL,
+	tier_minutes INTEGER[] NOT NULL,
+	quiet_start  INTEGER   NOT NULL DEFAULT 0,
+	quiet_end    INTEGER   NOT NULL DEFAULT 0,
+	timezone     TEXT      NOT NULL,
+	PRIMARY KEY (team_id, repo_slug)
+);
+
+-- Highest reminder tier already sent for a PR in a team.
+CREATE TABLE pr_reminders (
+	team_id   TEXT        NOT NULL,
+	repo_slug TEXT        NOT NULL,
+	pr_id     INTEGER     NOT NULL,
+	tier      INTEGER     NOT NULL,
+	sent_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (team_id, repo_slug, pr_id)
+);
@@ -0,0 +1,8 @@
+-- Personal DM notifications a linked Slack user has opted into via /notify.
+-- events holds store.Notify* kinds; no row means no personal notifications.
+CREATE TABLE notification_prefs (
+	slack_user_id TEXT PRIMARY KEY,
+	team_id       TEXT        NOT NULL,
+	events        TEXT[]      NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,7 @@
+-- Slack users who have opened the bot's App Home, so webhook events know
+-- whose Home tab to republish and with which team's bot token.
+CREATE TABLE home_views (
+	slack_user_id TEXT PRIMARY KEY,
+	team_id       TEXT        NOT NULL,
+	opened_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
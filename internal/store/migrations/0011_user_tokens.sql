@@ -0,0 +1,11 @@
+-- Per-user Bitbucket OAuth tokens saved by /login, used to act on PRs as that
+-- user. Encrypted the same way as bitbucket_tokens.
+CREATE TABLE user_tokens (
+	slack_user_id TEXT PRIMARY KEY,
+	access_token  TEXT        NOT NULL,
+	refresh_token TEXT        NOT NULL,
+	expires_at    TIMESTAMPTZ NOT NULL,
+	key_id        TEXT        NOT NULL DEFAULT '',
+	data_key      TEXT        NOT NULL DEFAULT '',
+	updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,18 @@
+-- Slack thread replies posted to Bitbucket as PR comments. A row is claimed
+-- per Slack message before posting, so Slack event retries are not posted
+-- twice, and the resulting comment_id keeps the comment_created webhook from
+-- echoing it back into the thread it came from.
+CREATE TABLE synced_comments (
+	channel_id TEXT        NOT NULL,
+	message_ts TEXT        NOT NULL,
+	repo_slug  TEXT        NOT NULL,
+	pr_id      INTEGER     NOT NULL,
+	comment_id INTEGER,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (channel_id, message_ts)
+);
+
+CREATE UNIQUE INDEX synced_comments_comment_idx ON synced_comments (repo_slug, comment_id);
+
+-- Thread replies are matched to their PR card by the card's ts.
+CREATE INDEX pr_messages_message_idx ON pr_messages (channel_id, message_ts);
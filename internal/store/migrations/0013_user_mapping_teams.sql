@@ -0,0 +1,24 @@
+-- Scope Slack ↔ Bitbucket user links to the Slack team they were made in, so a
+-- mention or DM never reaches a user of another installed team. Existing links
+-- take the team the user was seen in elsewhere, or the only team if there is one;
+-- links left without a team match nothing until the user runs /login again.
+ALTER TABLE user_mappings ADD COLUMN team_id TEXT NOT NULL DEFAULT '';
+
+UPDATE user_mappings m SET team_id = COALESCE(
+	(SELECT p.team_id FROM notification_prefs p WHERE p.slack_user_id = m.slack_user_id),
+	(SELECT v.team_id FROM home_views v WHERE v.slack_user_id = m.slack_user_id),
+	(SELECT MIN(t.team_id) FROM (
+		SELECT team_id FROM repo_subscriptions
+		UNION SELECT team_id FROM bitbucket_tokens
+	) t HAVING COUNT(*) = 1),
+	''
+);
+
+-- Display names are not unique, so several users of a team may share one.
+ALTER TABLE user_mappings
+	DROP CONSTRAINT IF EXISTS user_mappings_pkey,
+	DROP CONSTRAINT IF EXISTS user_mappings_bitbucket_username_key,
+	ADD PRIMARY KEY (team_id, slack_user_id),
+	ALTER COLUMN team_id DROP DEFAULT;
+
+CREATE INDEX user_mappings_bitbucket_idx ON user_mappings (team_id, bitbucket_username);
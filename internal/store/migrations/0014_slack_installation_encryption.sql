@@ -0,0 +1,5 @@
+-- Envelope encryption for Slack bot tokens, the same way as bitbucket_tokens.
+-- Rows with an empty key_id are still plaintext until `bot rotate-token-key` runs.
+ALTER TABLE slack_installations
+	ADD COLUMN key_id   TEXT NOT NULL DEFAULT '',
+	ADD COLUMN data_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Lease taken by the background token refresher, so only one bot instance
+-- refreshes a workspace's token at a time.
+ALTER TABLE bitbucket_tokens ADD COLUMN locked_until TIMESTAMPTZ;
@@ -0,0 +1,7 @@
+-- The linked account's nickname and UUID, which CODEOWNERS "@" handles refer to.
+-- Existing links get them when the user next runs /login.
+ALTER TABLE user_mappings
+	ADD COLUMN bitbucket_nickname TEXT NOT NULL DEFAULT '',
+	ADD COLUMN bitbucket_uuid     TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX user_mappings_nickname_idx ON user_mappings (team_id, lower(bitbucket_nickname));
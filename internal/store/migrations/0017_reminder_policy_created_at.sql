@@ -0,0 +1,4 @@
+-- When each reminder policy was first set. PRs opened earlier start escalating
+-- from this time rather than their own creation, so a new policy does not
+-- jump straight to its last tier for every old PR.
+ALTER TABLE reminder_policies ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
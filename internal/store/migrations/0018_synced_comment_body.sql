@@ -0,0 +1,4 @@
+-- The Markdown posted for each synced Slack reply. The comment_created webhook
+-- can arrive before comment_id is saved; a pending row with the same body is
+-- then recognised as the comment's origin.
+ALTER TABLE synced_comments ADD COLUMN body TEXT NOT NULL DEFAULT '';
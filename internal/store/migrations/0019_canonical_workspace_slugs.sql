@@ -0,0 +1,24 @@
+-- Workspace-wide subscriptions used to store the workspace as typed at
+-- /repo connect, so "Acme/*" never matched webhooks for "acme/api". Bitbucket
+-- workspace slugs are lower-case; fold existing workspace slugs to match.
+-- Where a channel holds several spellings, its earliest subscription is kept.
+DELETE FROM repo_subscriptions s
+USING repo_subscriptions o
+WHERE s.repo_slug LIKE '%/*'
+  AND o.channel_id = s.channel_id
+  AND lower(o.repo_slug) = lower(s.repo_slug)
+  AND o.id < s.id;
+
+UPDATE repo_subscriptions SET repo_slug = lower(repo_slug)
+WHERE repo_slug LIKE '%/*' AND repo_slug <> lower(repo_slug);
+
+-- The most recently created secret is the one last pushed to the workspace webhook.
+DELETE FROM webhook_secrets s
+USING webhook_secrets o
+WHERE s.repo_slug LIKE '%/*'
+  AND lower(o.repo_slug) = lower(s.repo_slug)
+  AND o.repo_slug <> s.repo_slug
+  AND (o.created_at > s.created_at OR (o.created_at = s.created_at AND o.repo_slug < s.repo_slug));
+
+UPDATE webhook_secrets SET repo_slug = lower(repo_slug)
+WHERE repo_slug LIKE '%/*' AND repo_slug <> lower(repo_slug);
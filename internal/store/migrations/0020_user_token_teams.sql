@@ -0,0 +1,16 @@
+-- Scope per-user Bitbucket tokens to the Slack team they were linked in, like
+-- user_mappings, so a /login in one installed team never acts for another.
+-- Existing tokens take the user's team if they are linked in exactly one;
+-- others match nothing until the user runs /login again.
+ALTER TABLE user_tokens ADD COLUMN team_id TEXT NOT NULL DEFAULT '';
+
+UPDATE user_tokens t SET team_id = COALESCE(
+	(SELECT MIN(m.team_id) FROM user_mappings m
+	 WHERE m.slack_user_id = t.slack_user_id HAVING COUNT(*) = 1),
+	''
+);
+
+ALTER TABLE user_tokens
+	DROP CONSTRAINT IF EXISTS user_tokens_pkey,
+	ADD PRIMARY KEY (team_id, slack_user_id),
+	ALTER COLUMN team_id DROP DEFAULT;
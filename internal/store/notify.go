@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Personal notification kinds, toggled per user with /notify.
+const (
+	NotifyReviewerAdded    = "reviewer_added"
+	NotifyApproved         = "approved"
+	NotifyChangesRequested = "changes_requested"
+	NotifyComment          = "comment"
+	NotifyBuildFailed      = "build_failed"
+)
+
+// NotifyKinds lists every personal notification kind in display order.
+var NotifyKinds = []string{
+	NotifyReviewerAdded, NotifyApproved, NotifyChangesRequested, NotifyComment, NotifyBuildFailed,
+}
+
+// GetNotificationPrefs returns the notification kinds slackUserID has turned on,
+// or nil if they have not opted into any.
+func (s *RepoStore) GetNotificationPrefs(ctx context.Context, slackUserID string) ([]string, error) {
+	var events []string
+	err := s.pool.QueryRow(ctx,
+		`SELECT events FROM notification_prefs WHERE slack_user_id = $1`, slackUserID,
+	).Scan(&events)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return events, err
+}
+
+// SaveNotificationPrefs replaces the notification kinds slackUserID has turned on.
+func (s *RepoStore) SaveNotificationPrefs(ctx context.Context, slackUserID, teamID string, events []string) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO notification_prefs (slack_user_id, team_id, events)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (slack_user_id) DO UPDATE SET
+			team_id    = EXCLUDED.team_id,
+			events     = EXCLUDED.events,
+			updated_at = NOW()
+	`, slackUserID, teamID, orEmpty(events))
+	return err
+}
+
+// NotificationRecipient is a Slack user to DM about a Bitbucket user's pull requests.
+type NotificationRecipient struct {
+	TeamID      string
+	SlackUserID string
+}
+
+// NotificationRecipients returns every user of teamIDs linked to a Bitbucket display
+// name who has turned on the given notification kind. Display names are not unique,
+// so there may be several.
+func (s *RepoStore) NotificationRecipients(ctx context.Context, teamIDs []string, bitbucketUsername, kind string) ([]NotificationRecipient, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT p.team_id, p.slack_user_id
+		FROM user_mappings m
+		JOIN notification_prefs p ON p.team_id = m.team_id AND p.slack_user_id = m.slack_user_id
+		WHERE m.team_id = ANY($1) AND m.bitbucket_username = $2 AND $3 = ANY(p.events)
+	`, teamIDs, bitbucketUsername, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []NotificationRecipient
+	for rows.Next() {
+		var r NotificationRecipient
+		if err := rows.Scan(&r.TeamID, &r.SlackUserID); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, rows.Err()
+}
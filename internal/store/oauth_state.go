@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// OAuthState is the server-side record behind an opaque OAuth2 state nonce.
+type OAuthState struct {
+	Kind      string // "connect" or "login"
+	TeamID    string
+	ChannelID string
+	UserID    string
+	Workspace string
+}
+
+// CreateOAuthState stores st under a fresh random nonce that expires after ttl and
+// returns the nonce to be used as the OAuth2 state parameter. Expired states are purged.
+func (s *RepoStore) CreateOAuthState(ctx context.Context, st OAuthState, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+
+	if _, err := s.pool.Exec(ctx, `DELETE FROM oauth_states WHERE expires_at < NOW()`); err != nil {
+		return "", err
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO oauth_states (nonce, kind, team_id, channel_id, user_id, workspace, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, nonce, st.Kind, st.TeamID, st.ChannelID, st.UserID, st.Workspace, time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+	return nonce, nil
+}
+
+// ConsumeOAuthState deletes and returns the state stored under nonce.
+// Returns nil if the nonce is unknown, already used, or expired.
+func (s *RepoStore) ConsumeOAuthState(ctx context.Context, nonce string) (*OAuthState, error) {
+	row := s.pool.QueryRow(ctx, `
+		DELETE FROM oauth_states WHERE nonce = $1
+		RETURNING kind, team_id, channel_id, user_id, workspace, expires_at
+	`, nonce)
+	var st OAuthState
+	var expiresAt time.Time
+	if err := row.Scan(&st.Kind, &st.TeamID, &st.ChannelID, &st.UserID, &st.Workspace, &expiresAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if time.Now().After(expiresAt) {
+		return nil, nil
+	}
+	return &st, nil
+}
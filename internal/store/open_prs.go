@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// OpenPR is a pull request with its review and build state. Despite the name,
+// ListUserPRs also returns recently merged ones; check State.
+type OpenPR struct {
+	PRCommitRecord
+	Approvals      []string // display names
+	ChangeRequests []string // display names
+	BuildState     string   // latest build state for the head commit, "" if none reported
+}
+
+// openPRSelect selects prCommitColumns followed by the OpenPR review and build columns.
+const openPRSelect = `
+	SELECT ` + prCommitColumns + `,
+	       COALESCE((SELECT array_agg(a.user_name) FROM pr_approvals a
+	                 WHERE a.repo_slug = c.repo_slug AND a.pr_id = c.pr_id), '{}'),
+	       COALESCE((SELECT array_agg(r.user_name) FROM pr_change_requests r
+	                 WHERE r.repo_slug = c.repo_slug AND r.pr_id = c.pr_id), '{}'),
+	       COALESCE(b.state, '')
+	FROM pr_commits c
+	LEFT JOIN build_statuses b ON b.repo_slug = c.repo_slug AND b.commit_hash = c.commit_hash`
+
+// ListOpenPRs returns every open PR in the given repositories, oldest first. slugs may
+// include workspace slugs (see WorkspaceSlug), which cover every repository in the workspace.
+func (s *RepoStore) ListOpenPRs(ctx context.Context, slugs []string) ([]OpenPR, error) {
+	return s.queryOpenPRs(ctx, openPRSelect+`
+		WHERE c.state = 'OPEN'
+		  AND (c.repo_slug = ANY($1) OR split_part(c.repo_slug, '/', 1) || '/*' = ANY($1))
+		ORDER BY c.created_at
+	`, slugs)
+}
+
+// ListUserPRs returns the open PRs that a Bitbucket user authored or reviews, and the
+// PRs they authored that were merged after mergedSince, oldest first.
+func (s *RepoStore) ListUserPRs(ctx context.Context, bitbucketUsername string, mergedSince time.Time) ([]OpenPR, error) {
+	return s.queryOpenPRs(ctx, openPRSelect+`
+		WHERE (c.state = 'OPEN' AND (c.author_name = $1 OR c.reviewer_names::jsonb ? $1))
+		   OR (c.state = 'MERGED' AND c.author_name = $1 AND c.updated_at > $2)
+		ORDER BY c.created_at
+	`, bitbucketUsername, mergedSince)
+}
+
+func (s *RepoStore) queryOpenPRs(ctx context.Context, sql string, args ...any) ([]OpenPR, error) {
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prs []OpenPR
+	for rows.Next() {
+		var pr OpenPR
+		if err := scanPRCommit(rows, &pr.PRCommitRecord, &pr.Approvals, &pr.ChangeRequests, &pr.BuildState); err != nil {
+			return nil, err
+		}
+		prs = append(prs, pr)
+	}
+	return prs, rows.Err()
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Outbox message kinds.
+const (
+	OutboxPost   = "post"   // post a new message; saved as the PR card when RepoSlug is set
+	OutboxUpdate = "update" // replace the PR card in the channel
+	OutboxReply  = "reply"  // thread reply under the PR card in the channel
+	OutboxDM     = "dm"     // direct message to the user in ChannelID, linking to the PR card when RepoSlug is set
+)
+
+// OutboxMessage is a pending Slack delivery. Update and reply messages reference
+// the PR card by repo and PR ID; its ts is looked up at delivery time, so they
+// can be queued before the card itself has been posted.
+type OutboxMessage struct {
+	ID        int64
+	TeamID    string
+	ChannelID string
+	Kind      string
+	RepoSlug  string
+	PRID      int
+	Text      string
+	Blocks    []byte // JSON-encoded Block Kit blocks, may be nil
+	Attempts  int
+}
+
+// EnqueueOutbox queues a message for delivery.
+func (s *RepoStore) EnqueueOutbox(ctx context.Context, m OutboxMessage) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO slack_outbox (team_id, channel_id, kind, repo_slug, pr_id, text, blocks)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, m.TeamID, m.ChannelID, m.Kind, m.RepoSlug, m.PRID, m.Text, m.Blocks)
+	return err
+}
+
+// ClaimOutbox leases the next deliverable message for lease and returns it, or nil
+// if nothing is due. Only the oldest live message of each channel is eligible,
+// which keeps per-channel delivery in enqueue order across all workers and replicas.
+func (s *RepoStore) ClaimOutbox(ctx context.Context, lease time.Duration) (*OutboxMessage, error) {
+	row := s.pool.QueryRow(ctx, `
+		WITH next AS (
+			SELECT o.id FROM slack_outbox o
+			WHERE NOT o.failed
+			  AND o.next_attempt_at <= NOW()
+			  AND (o.locked_until IS NULL OR o.locked_until < NOW())
+			  AND o.id = (SELECT MIN(p.id) FROM slack_outbox p WHERE p.channel_id = o.channel_id AND NOT p.failed)
+			ORDER BY o.id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE slack_outbox o SET
+			locked_until = NOW() + make_interval(secs => $1),
+			attempts     = o.attempts + 1
+		FROM next WHERE o.id = next.id
+		RETURNING o.id, o.team_id, o.channel_id, o.kind, o.repo_slug, o.pr_id, o.text, o.blocks, o.attempts
+	`, lease.Seconds())
+	var m OutboxMessage
+	if err := row.Scan(&m.ID, &m.TeamID, &m.ChannelID, &m.Kind, &m.RepoSlug, &m.PRID, &m.Text, &m.Blocks, &m.Attempts); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// CompleteOutbox removes a delivered message.
+func (s *RepoStore) CompleteOutbox(ctx context.Context, id int64) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM slack_outbox WHERE id = $1`, id)
+	return err
+}
+
+// RetryOutbox releases a message for another attempt at the given time.
+func (s *RepoStore) RetryOutbox(ctx context.Context, id int64, at time.Time, lastErr string) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE slack_outbox SET locked_until = NULL, next_attempt_at = $2, last_error = $3 WHERE id = $1
+	`, id, at, lastErr)
+	return err
+}
+
+// DeferOutbox releases a message for another attempt at the given time without
+// counting the claim as an attempt, for delays such as rate limits that say nothing
+// about the message itself.
+func (s *RepoStore) DeferOutbox(ctx context.Context, id int64, at time.Time, lastErr string) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE slack_outbox SET locked_until = NULL, next_attempt_at = $2, last_error = $3,
+			attempts = GREATEST(attempts - 1, 0)
+		WHERE id = $1
+	`, id, at, lastErr)
+	return err
+}
+
+// FailOutbox marks a message as permanently failed. Failed messages are kept for
+// inspection and no longer block later messages in the same channel.
+func (s *RepoStore) FailOutbox(ctx context.Context, id int64, lastErr string) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE slack_outbox SET locked_until = NULL, failed = TRUE, last_error = $2 WHERE id = $1
+	`, id, lastErr)
+	return err
+}
+
+// PurgeFailedOutbox deletes failed messages queued before cutoff and returns how many
+// were removed.
+func (s *RepoStore) PurgeFailedOutbox(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM slack_outbox WHERE failed AND created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PRChannel is a channel with a PR card. Subscribed is false once the channel has
+// unsubscribed from the repository and its workspace; Filter is then empty.
+type PRChannel struct {
+	Subscription
+	Subscribed bool
+}
+
+// GetPRChannels returns every channel that has — or is about to get — a card for a PR:
+// channels with a stored message ts plus channels with a card post still in the outbox.
+// Each carries the channel's subscription filter (its repository subscription, else its
+// workspace-wide one).
+func (s *RepoStore) GetPRChannels(ctx context.Context, repoSlug string, prID int) ([]PRChannel, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT c.channel_id, c.team_id, $1::text,
+		       COALESCE(rs.events, '{}'), COALESCE(rs.target_branches, '{}'),
+		       COALESCE(rs.source_branches, '{}'), COALESCE(rs.exclude_authors, '{}'), COALESCE(rs.paths, '{}'),
+		       rs.channel_id IS NOT NULL
+		FROM (
+			SELECT team_id, channel_id FROM pr_messages WHERE repo_slug = $1 AND pr_id = $2
+			UNION
+			SELECT team_id, channel_id FROM slack_outbox
+			WHERE kind = 'post' AND repo_slug = $1 AND pr_id = $2 AND NOT failed
+		) c
+		LEFT JOIN LATERAL (
+			SELECT * FROM repo_subscriptions r
+			WHERE r.channel_id = c.channel_id
+			  AND (r.repo_slug = $1 OR r.repo_slug = split_part($1, '/', 1) || '/*')
+			ORDER BY r.repo_slug = $1 DESC
+			LIMIT 1
+		) rs ON TRUE
+	`, repoSlug, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chans []PRChannel
+	for rows.Next() {
+		var ch PRChannel
+		if err := rows.Scan(&ch.ChannelID, &ch.TeamID, &ch.RepoSlug,
+			&ch.Filter.Events, &ch.Filter.TargetBranches, &ch.Filter.SourceBranches, &ch.Filter.ExcludeAuthors, &ch.Filter.Paths,
+			&ch.Subscribed); err != nil {
+			return nil, err
+		}
+		chans = append(chans, ch)
+	}
+	return chans, rows.Err()
+}
+
+// GetPRMessageTS returns the card ts for a PR in channelID, or "" if none is stored.
+func (s *RepoStore) GetPRMessageTS(ctx context.Context, repoSlug string, prID int, channelID string) (string, error) {
+	row := s.pool.QueryRow(ctx,
+		`SELECT message_ts FROM pr_messages WHERE repo_slug = $1 AND pr_id = $2 AND channel_id = $3`,
+		repoSlug, prID, channelID,
+	)
+	var ts string
+	if err := row.Scan(&ts); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+	return ts, nil
+}
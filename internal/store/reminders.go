@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// ReminderPolicy controls review reminders for a repository, or for a whole
+// workspace when RepoSlug is a workspace slug.
+type ReminderPolicy struct {
+	TeamID     string
+	RepoSlug   string
+	Tiers      []time.Duration // PR ages at which to remind, ascending
+	QuietStart int             // minutes after local midnight
+	QuietEnd   int             // equal to QuietStart when there are no quiet hours
+	Timezone   string
+	CreatedAt  time.Time // when the policy was first set; kept when it is replaced
+}
+
+// Quiet reports whether t falls within the policy's quiet hours. The window may
+// wrap around midnight, e.g. 19:00–08:00.
+func (p ReminderPolicy) Quiet(t time.Time) bool {
+	if p.QuietStart == p.QuietEnd {
+		return false
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	m := local.Hour()*60 + local.Minute()
+	if p.QuietStart < p.QuietEnd {
+		return m >= p.QuietStart && m < p.QuietEnd
+	}
+	return m >= p.QuietStart || m < p.QuietEnd
+}
+
+// DueTier returns how many tiers a PR opened at createdAt has reached at now (0 for
+// none). A PR opened before the policy existed is aged from the policy's creation.
+func (p ReminderPolicy) DueTier(createdAt, now time.Time) int {
+	if createdAt.Before(p.CreatedAt) {
+		createdAt = p.CreatedAt
+	}
+	age := now.Sub(createdAt)
+	n := 0
+	for _, t := range p.Tiers {
+		if age >= t {
+			n++
+		}
+	}
+	return n
+}
+
+// SaveReminderPolicy creates or replaces the policy for p.TeamID and p.RepoSlug.
+func (s *RepoStore) SaveReminderPolicy(ctx context.Context, p ReminderPolicy) error {
+	minutes := make([]int32, len(p.Tiers))
+	for i, t := range p.Tiers {
+		minutes[i] = int32(t / time.Minute)
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO reminder_policies (team_id, repo_slug, tier_minutes, quiet_start, quiet_end, timezone)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (team_id, repo_slug) DO UPDATE SET
+			tier_minutes = EXCLUDED.tier_minutes,
+			quiet_start  = EXCLUDED.quiet_start,
+			quiet_end    = EXCLUDED.quiet_end,
+			timezone     = EXCLUDED.timezone
+	`, p.TeamID, p.RepoSlug, minutes, p.QuietStart, p.QuietEnd, p.Timezone)
+	return err
+}
+
+// DeleteReminderPolicy removes a policy. It reports whether one existed.
+func (s *RepoStore) DeleteReminderPolicy(ctx context.Context, teamID, repoSlug string) (bool, error) {
+	tag, err := s.pool.Exec(ctx,
+		`DELETE FROM reminder_policies WHERE team_id = $1 AND repo_slug = $2`, teamID, repoSlug)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListReminderPolicies returns every reminder policy, or only teamID's when it is non-empty.
+func (s *RepoStore) ListReminderPolicies(ctx context.Context, teamID string) ([]ReminderPolicy, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT team_id, repo_slug, tier_minutes, quiet_start, quiet_end, timezone, created_at
+		FROM reminder_policies WHERE $1 = '' OR team_id = $1
+		ORDER BY team_id, repo_slug
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []ReminderPolicy
+	for rows.Next() {
+		var p ReminderPolicy
+		var minutes []int32
+		if err := rows.Scan(&p.TeamID, &p.RepoSlug, &minutes, &p.QuietStart, &p.QuietEnd, &p.Timezone, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		for _, m := range minutes {
+			p.Tiers = append(p.Tiers, time.Duration(m)*time.Minute)
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// AdvancePRReminder records that tier has been reached for a PR in teamID. It
+// reports false if that tier (or a later one) was already recorded, so that only
+// one bot instance sends each reminder.
+func (s *RepoStore) AdvancePRReminder(ctx context.Context, teamID, repoSlug string, prID, tier int) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO pr_reminders (team_id, repo_slug, pr_id, tier)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (team_id, repo_slug, pr_id) DO UPDATE SET tier = EXCLUDED.tier, sent_at = NOW()
+		WHERE pr_reminders.tier < EXCLUDED.tier
+	`, teamID, repoSlug, prID, tier)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -6,103 +6,40 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"bitbucket-slack-bot/internal/secrets"
+
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // RepoStore persists channel→repo subscriptions and Bitbucket OAuth tokens in PostgreSQL.
 type RepoStore struct {
-	pool *pgxpool.Pool
-}
-
-func NewRepoStore(pool *pgxpool.Pool) *RepoStore {
-	return &RepoStore{pool: pool}
+	pool    *pgxpool.Pool
+	keyring *secrets.Keyring
 }
 
-// Migrate creates all required tables if they do not already exist.
-func (s *RepoStore) Migrate(ctx context.Context) error {
-	_, err := s.pool.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS repo_subscriptions (
-			id         SERIAL PRIMARY KEY,
-			channel_id TEXT        NOT NULL,
-			team_id    TEXT        NOT NULL,
-			repo_slug  TEXT        NOT NULL,
-			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-			UNIQUE(channel_id, repo_slug)
-		);
-
-		CREATE TABLE IF NOT EXISTS bitbucket_tokens (
-			team_id       TEXT PRIMARY KEY,
-			workspace     TEXT        NOT NULL,
-			access_token  TEXT        NOT NULL,
-			refresh_token TEXT        NOT NULL,
-			expires_at    TIMESTAMPTZ NOT NULL,
-			updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		);
-
-		CREATE TABLE IF NOT EXISTS webhook_secrets (
-			repo_slug  TEXT PRIMARY KEY,
-			secret     TEXT        NOT NULL,
-			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		);
-
-		CREATE TABLE IF NOT EXISTS pr_messages (
-			repo_slug   TEXT    NOT NULL,
-			pr_id       INTEGER NOT NULL,
-			channel_id  TEXT    NOT NULL,
-			message_ts  TEXT    NOT NULL,
-			PRIMARY KEY (repo_slug, pr_id, channel_id)
-		);
-
-		CREATE TABLE IF NOT EXISTS pr_approvals (
-			repo_slug   TEXT    NOT NULL,
-			pr_id       INTEGER NOT NULL,
-			user_name   TEXT    NOT NULL,
-			PRIMARY KEY (repo_slug, pr_id, user_name)
-		);
-
-		CREATE TABLE IF NOT EXISTS user_mappings (
-			slack_user_id      TEXT PRIMARY KEY,
-			bitbucket_username TEXT NOT NULL UNIQUE,
-			created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		);
-
-		CREATE TABLE IF NOT EXISTS pr_commits (
-			repo_slug      TEXT    NOT NULL,
-			pr_id          INTEGER NOT NULL,
-			commit_hash    TEXT    NOT NULL,
-			pr_title       TEXT    NOT NULL,
-			pr_url         TEXT    NOT NULL,
-			author_name    TEXT    NOT NULL,
-			reviewer_names TEXT    NOT NULL DEFAULT '[]',
-			source_branch  TEXT    NOT NULL,
-			dest_branch    TEXT    NOT NULL,
-			PRIMARY KEY (repo_slug, pr_id)
-		);
-		CREATE INDEX IF NOT EXISTS idx_pr_commits_hash ON pr_commits (repo_slug, commit_hash);
-
-		CREATE TABLE IF NOT EXISTS build_statuses (
-			repo_slug   TEXT        NOT NULL,
-			commit_hash TEXT        NOT NULL,
-			state       TEXT        NOT NULL,
-			name        TEXT        NOT NULL,
-			url         TEXT        NOT NULL,
-			updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-			PRIMARY KEY (repo_slug, commit_hash)
-		);
-	`)
-	return err
+// NewRepoStore creates a store. OAuth tokens are encrypted at rest with keyring;
+// a nil keyring stores them in plaintext.
+func NewRepoStore(pool *pgxpool.Pool, keyring *secrets.Keyring) *RepoStore {
+	return &RepoStore{pool: pool, keyring: keyring}
 }
 
-// Subscribe registers channel to receive PR notifications for repoSlug.
-// Duplicate subscriptions are silently ignored.
-func (s *RepoStore) Subscribe(ctx context.Context, channelID, teamID, repoSlug string) error {
+// Subscribe registers channel to receive PR notifications for repoSlug, narrowed by filter.
+// Subscribing again replaces the existing filter.
+func (s *RepoStore) Subscribe(ctx context.Context, channelID, teamID, repoSlug string, filter SubscriptionFilter) error {
 	_, err := s.pool.Exec(ctx,
-		`INSERT INTO repo_subscriptions (channel_id, team_id, repo_slug)
-		 VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
-		channelID, teamID, repoSlug,
+		`INSERT INTO repo_subscriptions (channel_id, team_id, repo_slug, events, target_branches, source_branches, exclude_authors, paths)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (channel_id, repo_slug) DO UPDATE
+		 SET events = EXCLUDED.events, target_branches = EXCLUDED.target_branches,
+		     source_branches = EXCLUDED.source_branches, exclude_authors = EXCLUDED.exclude_authors,
+		     paths = EXCLUDED.paths`,
+		channelID, teamID, repoSlug, orEmpty(filter.Events), orEmpty(filter.TargetBranches),
+		orEmpty(filter.SourceBranches), orEmpty(filter.ExcludeAuthors), orEmpty(filter.Paths),
 	)
 	return err
 }
@@ -116,26 +53,81 @@ func (s *RepoStore) Unsubscribe(ctx context.Context, channelID, repoSlug string)
 	return err
 }
 
-// ChannelsForRepo returns all channel IDs subscribed to repoSlug.
-func (s *RepoStore) ChannelsForRepo(ctx context.Context, repoSlug string) ([]string, error) {
+// Subscription is a single channel's subscription to a repository.
+type Subscription struct {
+	ChannelID string
+	TeamID    string
+	RepoSlug  string
+	Filter    SubscriptionFilter
+}
+
+// WorkspaceSlug returns the subscription slug covering every repository in workspace ("acme/*").
+func WorkspaceSlug(workspace string) string {
+	return workspace + "/*"
+}
+
+// IsWorkspaceSlug reports whether slug is a workspace-wide subscription slug.
+func IsWorkspaceSlug(slug string) bool {
+	return strings.HasSuffix(slug, "/*")
+}
+
+// ChannelsForRepo returns all subscriptions (channel + Slack team + filter) for repoSlug,
+// including workspace-wide subscriptions to its workspace. A channel subscribed both ways
+// is returned once, with its repository subscription taking precedence.
+func (s *RepoStore) ChannelsForRepo(ctx context.Context, repoSlug string) ([]Subscription, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT channel_id FROM repo_subscriptions WHERE repo_slug = $1`,
+		`SELECT DISTINCT ON (channel_id)
+		        channel_id, team_id, repo_slug, events, target_branches, source_branches, exclude_authors, paths
+		 FROM repo_subscriptions
+		 WHERE repo_slug = $1 OR repo_slug = split_part($1, '/', 1) || '/*'
+		 ORDER BY channel_id, repo_slug = $1 DESC`,
 		repoSlug,
 	)
 	if err != nil {
 		return nil, err
 	}
+	return collectSubscriptions(rows)
+}
+
+// HasSubscribers reports whether any channel is subscribed to exactly repoSlug
+// (a repository, or a workspace slug from WorkspaceSlug).
+func (s *RepoStore) HasSubscribers(ctx context.Context, repoSlug string) (bool, error) {
+	var exists bool
+	err := s.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM repo_subscriptions WHERE repo_slug = $1)`,
+		repoSlug,
+	).Scan(&exists)
+	return exists, err
+}
+
+// SubscriptionsForChannel returns every subscription in channelID, ordered by subscription time.
+func (s *RepoStore) SubscriptionsForChannel(ctx context.Context, channelID string) ([]Subscription, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT channel_id, team_id, repo_slug, events, target_branches, source_branches, exclude_authors, paths
+		 FROM repo_subscriptions WHERE channel_id = $1 ORDER BY created_at`,
+		channelID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectSubscriptions(rows)
+}
+
+// collectSubscriptions scans rows of
+// (channel_id, team_id, repo_slug, events, target_branches, source_branches, exclude_authors, paths).
+func collectSubscriptions(rows pgx.Rows) ([]Subscription, error) {
 	defer rows.Close()
 
-	var channels []string
+	var subs []Subscription
 	for rows.Next() {
-		var ch string
-		if err := rows.Scan(&ch); err != nil {
+		var sub Subscription
+		if err := rows.Scan(&sub.ChannelID, &sub.TeamID, &sub.RepoSlug,
+			&sub.Filter.Events, &sub.Filter.TargetBranches, &sub.Filter.SourceBranches, &sub.Filter.ExcludeAuthors, &sub.Filter.Paths); err != nil {
 			return nil, err
 		}
-		channels = append(channels, ch)
+		subs = append(subs, sub)
 	}
-	return channels, rows.Err()
+	return subs, rows.Err()
 }
 
 // ListForChannel returns all repo slugs subscribed in channelID, ordered by subscription time.
@@ -167,38 +159,229 @@ type TokenRecord struct {
 	AccessToken  string
 	RefreshToken string
 	ExpiresAt    time.Time
+	// ConnectedBy is the Slack user who ran /repo connect.
+	ConnectedBy string
+	// Disconnected is set once the refresh token has been rejected; the workspace
+	// must be reconnected before its tokens can be used again.
+	Disconnected bool
 }
 
-// SaveToken stores or updates OAuth tokens for a team.
-func (s *RepoStore) SaveToken(ctx context.Context, teamID, workspace, accessToken, refreshToken string, expiresAt time.Time) error {
-	_, err := s.pool.Exec(ctx, `
-		INSERT INTO bitbucket_tokens (team_id, workspace, access_token, refresh_token, expires_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
+// SaveToken stores or updates OAuth tokens for a team, encrypting them if a keyring is configured.
+// Saving always clears the disconnected flag and releases a refresher claim.
+func (s *RepoStore) SaveToken(ctx context.Context, teamID, workspace, accessToken, refreshToken string, expiresAt time.Time, connectedBy string) error {
+	keyID, dataKey, sealed, err := s.sealTokens(accessToken, refreshToken)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO bitbucket_tokens (team_id, workspace, access_token, refresh_token, expires_at, key_id, data_key, connected_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
 		ON CONFLICT (team_id) DO UPDATE SET
-			workspace     = EXCLUDED.workspace,
-			access_token  = EXCLUDED.access_token,
-			refresh_token = EXCLUDED.refresh_token,
-			expires_at    = EXCLUDED.expires_at,
-			updated_at    = NOW()
-	`, teamID, workspace, accessToken, refreshToken, expiresAt)
+			workspace       = EXCLUDED.workspace,
+			access_token    = EXCLUDED.access_token,
+			refresh_token   = EXCLUDED.refresh_token,
+			expires_at      = EXCLUDED.expires_at,
+			key_id          = EXCLUDED.key_id,
+			data_key        = EXCLUDED.data_key,
+			connected_by    = EXCLUDED.connected_by,
+			disconnected_at = NULL,
+			locked_until    = NULL,
+			updated_at      = NOW()
+	`, teamID, workspace, sealed[0], sealed[1], expiresAt, keyID, dataKey, connectedBy)
 	return err
 }
 
-// GetToken retrieves OAuth tokens for a team. Returns pgx.ErrNoRows if not found.
+// tokenColumns is the column list scanned by scanToken.
+const tokenColumns = `team_id, workspace, access_token, refresh_token, expires_at, key_id, data_key,
+	connected_by, disconnected_at IS NOT NULL`
+
+// scanToken scans a row selected with tokenColumns and decrypts its tokens.
+func (s *RepoStore) scanToken(row pgx.Row) (*TokenRecord, error) {
+	var t TokenRecord
+	var keyID, dataKey string
+	if err := row.Scan(&t.TeamID, &t.Workspace, &t.AccessToken, &t.RefreshToken, &t.ExpiresAt,
+		&keyID, &dataKey, &t.ConnectedBy, &t.Disconnected); err != nil {
+		return nil, err
+	}
+	opened, err := s.openTokens(keyID, dataKey, t.AccessToken, t.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt token for team %s: %w", t.TeamID, err)
+	}
+	t.AccessToken, t.RefreshToken = opened[0], opened[1]
+	return &t, nil
+}
+
+// GetToken retrieves OAuth tokens for a team. Returns nil if not found.
 func (s *RepoStore) GetToken(ctx context.Context, teamID string) (*TokenRecord, error) {
 	row := s.pool.QueryRow(ctx,
-		`SELECT team_id, workspace, access_token, refresh_token, expires_at
-		 FROM bitbucket_tokens WHERE team_id = $1`,
+		`SELECT `+tokenColumns+` FROM bitbucket_tokens WHERE team_id = $1`,
 		teamID,
 	)
-	var t TokenRecord
-	if err := row.Scan(&t.TeamID, &t.Workspace, &t.AccessToken, &t.RefreshToken, &t.ExpiresAt); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil
-		}
+	t, err := s.scanToken(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return t, err
+}
+
+// ClaimExpiringTokens locks every connected workspace's tokens that expire before t for
+// lease and returns them. A claimed row is not returned again until the lease expires or
+// the refreshed tokens are saved with SaveToken, so several bot instances never spend
+// the same refresh token twice.
+func (s *RepoStore) ClaimExpiringTokens(ctx context.Context, t time.Time, lease time.Duration) ([]TokenRecord, error) {
+	rows, err := s.pool.Query(ctx, `
+		UPDATE bitbucket_tokens SET locked_until = NOW() + make_interval(secs => $2)
+		WHERE disconnected_at IS NULL AND expires_at < $1
+		  AND (locked_until IS NULL OR locked_until < NOW())
+		RETURNING `+tokenColumns,
+		t, lease.Seconds(),
+	)
+	if err != nil {
 		return nil, err
 	}
-	return &t, nil
+	defer rows.Close()
+
+	var recs []TokenRecord
+	for rows.Next() {
+		rec, err := s.scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, *rec)
+	}
+	return recs, rows.Err()
+}
+
+// MarkTokenDisconnected flags a team's workspace connection as broken.
+func (s *RepoStore) MarkTokenDisconnected(ctx context.Context, teamID string) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE bitbucket_tokens SET disconnected_at = NOW() WHERE team_id = $1`,
+		teamID,
+	)
+	return err
+}
+
+// ReencryptTokens re-seals every token row — workspace, per-user and Slack bot — that is
+// not yet under the active key (including plaintext rows) and returns how many rows were
+// rewritten. Each row is rewritten only if it is unchanged since it was read, so a
+// concurrent refresh is never overwritten with stale tokens.
+func (s *RepoStore) ReencryptTokens(ctx context.Context) (int, error) {
+	if s.keyring == nil {
+		return 0, errors.New("no encryption key configured")
+	}
+	n := 0
+	for _, t := range []struct {
+		table        string
+		keys, tokens []string
+	}{
+		{"bitbucket_tokens", []string{"team_id"}, []string{"access_token", "refresh_token"}},
+		{"user_tokens", []string{"team_id", "slack_user_id"}, []string{"access_token", "refresh_token"}},
+		{"slack_installations", []string{"team_id"}, []string{"bot_token"}},
+	} {
+		m, err := s.reencryptTable(ctx, t.table, t.keys, t.tokens)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// reencryptTable implements ReencryptTokens for the token columns of one table keyed by keyColumns.
+func (s *RepoStore) reencryptTable(ctx context.Context, table string, keyColumns, tokenColumns []string) (int, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+strings.Join(keyColumns, ", ")+`, key_id, data_key, `+strings.Join(tokenColumns, ", ")+` FROM `+table+` WHERE key_id <> $1`,
+		s.keyring.ActiveID(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	type sealedRow struct {
+		keyID, dataKey string
+		ids, tokens    []string
+	}
+	var pending []sealedRow
+	for rows.Next() {
+		r := sealedRow{ids: make([]string, len(keyColumns)), tokens: make([]string, len(tokenColumns))}
+		var dest []any
+		for i := range r.ids {
+			dest = append(dest, &r.ids[i])
+		}
+		dest = append(dest, &r.keyID, &r.dataKey)
+		for i := range r.tokens {
+			dest = append(dest, &r.tokens[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// The first k parameters are the row key, then the new key_id and data_key, the ones
+	// read, the sealed tokens and last the first token as read.
+	k := len(keyColumns)
+	where := make([]string, k)
+	for i, col := range keyColumns {
+		where[i] = fmt.Sprintf("%s = $%d", col, i+1)
+	}
+	set := make([]string, len(tokenColumns))
+	for i, col := range tokenColumns {
+		set[i] = fmt.Sprintf("%s = $%d", col, k+i+5)
+	}
+	query := `UPDATE ` + table + fmt.Sprintf(` SET key_id = $%d, data_key = $%d, `, k+1, k+2) + strings.Join(set, ", ") + `
+		WHERE ` + strings.Join(where, " AND ") + fmt.Sprintf(` AND key_id = $%d AND data_key = $%d AND %s = $%d`, k+3, k+4, tokenColumns[0], k+len(tokenColumns)+5)
+
+	n := 0
+	for _, r := range pending {
+		opened, err := s.openTokens(r.keyID, r.dataKey, r.tokens...)
+		if err != nil {
+			return n, fmt.Errorf("decrypt token for %s %s: %w", strings.Join(keyColumns, ", "), strings.Join(r.ids, ", "), err)
+		}
+		keyID, dataKey, sealed, err := s.sealTokens(opened...)
+		if err != nil {
+			return n, err
+		}
+		var args []any
+		for _, id := range r.ids {
+			args = append(args, id)
+		}
+		args = append(args, keyID, dataKey, r.keyID, r.dataKey)
+		for _, v := range sealed {
+			args = append(args, v)
+		}
+		args = append(args, r.tokens[0])
+		tag, err := s.pool.Exec(ctx, query, args...)
+		if err != nil {
+			return n, err
+		}
+		n += int(tag.RowsAffected())
+	}
+	return n, nil
+}
+
+// sealTokens encrypts token values under the active key, or returns them unchanged
+// (with an empty key ID) when no keyring is configured.
+func (s *RepoStore) sealTokens(values ...string) (keyID, dataKey string, sealed []string, err error) {
+	if s.keyring == nil {
+		return "", "", values, nil
+	}
+	return s.keyring.Seal(values...)
+}
+
+// openTokens decrypts token values sealed by sealTokens. Rows with an empty key ID are plaintext.
+func (s *RepoStore) openTokens(keyID, dataKey string, values ...string) ([]string, error) {
+	if keyID == "" {
+		return values, nil
+	}
+	if s.keyring == nil {
+		return nil, fmt.Errorf("token is encrypted with key %q but no keyring is configured", keyID)
+	}
+	return s.keyring.Open(keyID, dataKey, values...)
 }
 
 // DeleteToken removes OAuth tokens for a team.
@@ -207,26 +390,29 @@ func (s *RepoStore) DeleteToken(ctx context.Context, teamID string) error {
 	return err
 }
 
-// PRMessage holds the Slack channel and message timestamp for a PR notification.
+// PRMessage holds the Slack team, channel and message timestamp for a PR notification.
 type PRMessage struct {
+	TeamID    string
 	ChannelID string
 	MessageTS string
 }
 
 // SavePRMessage stores (or replaces) the Slack message ts for a PR in a channel.
-func (s *RepoStore) SavePRMessage(ctx context.Context, repoSlug string, prID int, channelID, messageTS string) error {
+func (s *RepoStore) SavePRMessage(ctx context.Context, repoSlug string, prID int, teamID, channelID, messageTS string) error {
 	_, err := s.pool.Exec(ctx, `
-		INSERT INTO pr_messages (repo_slug, pr_id, channel_id, message_ts)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (repo_slug, pr_id, channel_id) DO UPDATE SET message_ts = EXCLUDED.message_ts
-	`, repoSlug, prID, channelID, messageTS)
+		INSERT INTO pr_messages (repo_slug, pr_id, team_id, channel_id, message_ts)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (repo_slug, pr_id, channel_id) DO UPDATE SET
+			team_id    = EXCLUDED.team_id,
+			message_ts = EXCLUDED.message_ts
+	`, repoSlug, prID, teamID, channelID, messageTS)
 	return err
 }
 
 // GetPRMessages returns all channel+ts pairs for a PR (used to thread follow-up events).
 func (s *RepoStore) GetPRMessages(ctx context.Context, repoSlug string, prID int) ([]PRMessage, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT channel_id, message_ts FROM pr_messages WHERE repo_slug = $1 AND pr_id = $2`,
+		`SELECT team_id, channel_id, message_ts FROM pr_messages WHERE repo_slug = $1 AND pr_id = $2`,
 		repoSlug, prID,
 	)
 	if err != nil {
@@ -237,7 +423,7 @@ func (s *RepoStore) GetPRMessages(ctx context.Context, repoSlug string, prID int
 	var msgs []PRMessage
 	for rows.Next() {
 		var m PRMessage
-		if err := rows.Scan(&m.ChannelID, &m.MessageTS); err != nil {
+		if err := rows.Scan(&m.TeamID, &m.ChannelID, &m.MessageTS); err != nil {
 			return nil, err
 		}
 		msgs = append(msgs, m)
@@ -330,23 +516,108 @@ func (s *RepoStore) GetApprovals(ctx context.Context, repoSlug string, prID int)
 	return names, rows.Err()
 }
 
-// SaveUserMapping stores or updates the link between a Slack user and their Bitbucket display name.
-func (s *RepoStore) SaveUserMapping(ctx context.Context, slackUserID, bitbucketUsername string) error {
+// AddChangeRequest records a "request changes" review on a PR by userName. Duplicates are ignored.
+func (s *RepoStore) AddChangeRequest(ctx context.Context, repoSlug string, prID int, userName string) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO pr_change_requests (repo_slug, pr_id, user_name) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+		repoSlug, prID, userName,
+	)
+	return err
+}
+
+// RemoveChangeRequest deletes a "request changes" review on a PR by userName.
+func (s *RepoStore) RemoveChangeRequest(ctx context.Context, repoSlug string, prID int, userName string) error {
+	_, err := s.pool.Exec(ctx,
+		`DELETE FROM pr_change_requests WHERE repo_slug = $1 AND pr_id = $2 AND user_name = $3`,
+		repoSlug, prID, userName,
+	)
+	return err
+}
+
+// GetChangeRequests returns the names of everyone currently requesting changes on a PR,
+// ordered by insertion time.
+func (s *RepoStore) GetChangeRequests(ctx context.Context, repoSlug string, prID int) ([]string, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT user_name FROM pr_change_requests WHERE repo_slug = $1 AND pr_id = $2 ORDER BY ctid`,
+		repoSlug, prID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// SaveUserMapping stores or updates the link between a Slack user of teamID and their
+// Bitbucket account: its display name, nickname and UUID.
+func (s *RepoStore) SaveUserMapping(ctx context.Context, teamID, slackUserID, bitbucketUsername, nickname, uuid string) error {
 	_, err := s.pool.Exec(ctx, `
-		INSERT INTO user_mappings (slack_user_id, bitbucket_username)
-		VALUES ($1, $2)
-		ON CONFLICT (slack_user_id) DO UPDATE SET bitbucket_username = EXCLUDED.bitbucket_username
-	`, slackUserID, bitbucketUsername)
+		INSERT INTO user_mappings (team_id, slack_user_id, bitbucket_username, bitbucket_nickname, bitbucket_uuid)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (team_id, slack_user_id) DO UPDATE SET
+			bitbucket_username = EXCLUDED.bitbucket_username,
+			bitbucket_nickname = EXCLUDED.bitbucket_nickname,
+			bitbucket_uuid     = EXCLUDED.bitbucket_uuid
+	`, teamID, slackUserID, bitbucketUsername, nickname, uuid)
 	return err
 }
 
-// GetSlackUserByBitbucket returns the Slack user ID linked to a Bitbucket display name,
-// or "" if no mapping exists.
-func (s *RepoStore) GetSlackUserByBitbucket(ctx context.Context, bitbucketUsername string) (string, error) {
+// GetBitbucketUser returns the Bitbucket display name linked to a Slack user of teamID,
+// or "" if the user has not run /login.
+func (s *RepoStore) GetBitbucketUser(ctx context.Context, teamID, slackUserID string) (string, error) {
 	row := s.pool.QueryRow(ctx,
-		`SELECT slack_user_id FROM user_mappings WHERE bitbucket_username = $1`,
-		bitbucketUsername,
+		`SELECT bitbucket_username FROM user_mappings WHERE team_id = $1 AND slack_user_id = $2`,
+		teamID, slackUserID,
 	)
+	var name string
+	if err := row.Scan(&name); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+// GetSlackUserByBitbucket returns the Slack user of teamID linked to a Bitbucket display
+// name, or "" if no mapping exists. If several users share the name, the most recent
+// link wins.
+func (s *RepoStore) GetSlackUserByBitbucket(ctx context.Context, teamID, bitbucketUsername string) (string, error) {
+	row := s.pool.QueryRow(ctx, `
+		SELECT slack_user_id FROM user_mappings
+		WHERE team_id = $1 AND bitbucket_username = $2
+		ORDER BY created_at DESC LIMIT 1
+	`, teamID, bitbucketUsername)
+	var id string
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+	return id, nil
+}
+
+// GetSlackUserByBitbucketHandle returns the Slack user of teamID linked to the Bitbucket
+// account with the given nickname (case-insensitive) or UUID (with or without braces),
+// or "" if no mapping exists.
+func (s *RepoStore) GetSlackUserByBitbucketHandle(ctx context.Context, teamID, handle string) (string, error) {
+	row := s.pool.QueryRow(ctx, `
+		SELECT slack_user_id FROM user_mappings
+		WHERE team_id = $1
+		  AND (lower(bitbucket_nickname) = lower($2) OR lower(btrim(bitbucket_uuid, '{}')) = lower(btrim($2, '{}')))
+		  AND $2 <> ''
+		ORDER BY created_at DESC LIMIT 1
+	`, teamID, handle)
 	var id string
 	if err := row.Scan(&id); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -368,14 +639,28 @@ type PRCommitRecord struct {
 	ReviewerNames []string
 	SourceBranch  string
 	DestBranch    string
+	ChangedPaths  []string // nil when unknown
+	State         string   // OPEN, MERGED, DECLINED or SUPERSEDED
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
 
+// PR states as reported by Bitbucket.
+const (
+	PROpen     = "OPEN"
+	PRMerged   = "MERGED"
+	PRDeclined = "DECLINED"
+	// PRUnknown marks rows saved before PR states were recorded.
+	PRUnknown = "UNKNOWN"
+)
+
 // SavePRCommit upserts the PR info and source commit hash.
 func (s *RepoStore) SavePRCommit(ctx context.Context, rec PRCommitRecord) error {
 	reviewersJSON, _ := json.Marshal(rec.ReviewerNames)
 	_, err := s.pool.Exec(ctx, `
-		INSERT INTO pr_commits (repo_slug, pr_id, commit_hash, pr_title, pr_url, author_name, reviewer_names, source_branch, dest_branch)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO pr_commits (repo_slug, pr_id, commit_hash, pr_title, pr_url, author_name, reviewer_names, source_branch, dest_branch, changed_paths,
+		                        state, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		ON CONFLICT (repo_slug, pr_id) DO UPDATE SET
 			commit_hash    = EXCLUDED.commit_hash,
 			pr_title       = EXCLUDED.pr_title,
@@ -383,31 +668,60 @@ func (s *RepoStore) SavePRCommit(ctx context.Context, rec PRCommitRecord) error
 			author_name    = EXCLUDED.author_name,
 			reviewer_names = EXCLUDED.reviewer_names,
 			source_branch  = EXCLUDED.source_branch,
-			dest_branch    = EXCLUDED.dest_branch
+			dest_branch    = EXCLUDED.dest_branch,
+			changed_paths  = EXCLUDED.changed_paths,
+			state          = EXCLUDED.state,
+			created_at     = EXCLUDED.created_at,
+			updated_at     = GREATEST(pr_commits.updated_at, EXCLUDED.updated_at)
 	`, rec.RepoSlug, rec.PRID, rec.CommitHash, rec.Title, rec.URL,
-		rec.AuthorName, string(reviewersJSON), rec.SourceBranch, rec.DestBranch)
+		rec.AuthorName, string(reviewersJSON), rec.SourceBranch, rec.DestBranch, rec.ChangedPaths,
+		rec.State, rec.CreatedAt, rec.UpdatedAt)
 	return err
 }
 
+// prCommitColumns lists the pr_commits columns read by scanPRCommit, qualified with alias c.
+const prCommitColumns = `c.repo_slug, c.pr_id, c.commit_hash, c.pr_title, c.pr_url, c.author_name, c.reviewer_names,
+	c.source_branch, c.dest_branch, c.changed_paths, c.state, c.created_at, c.updated_at`
+
+// scanPRCommit scans prCommitColumns into rec, followed by any extra destinations.
+func scanPRCommit(row pgx.Row, rec *PRCommitRecord, extra ...any) error {
+	var reviewersJSON string
+	dest := append([]any{&rec.RepoSlug, &rec.PRID, &rec.CommitHash, &rec.Title, &rec.URL,
+		&rec.AuthorName, &reviewersJSON, &rec.SourceBranch, &rec.DestBranch, &rec.ChangedPaths,
+		&rec.State, &rec.CreatedAt, &rec.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	json.Unmarshal([]byte(reviewersJSON), &rec.ReviewerNames)
+	return nil
+}
+
 // GetPRCommit retrieves the cached PR info. Returns nil if not found.
 func (s *RepoStore) GetPRCommit(ctx context.Context, repoSlug string, prID int) (*PRCommitRecord, error) {
-	row := s.pool.QueryRow(ctx, `
-		SELECT repo_slug, pr_id, commit_hash, pr_title, pr_url, author_name, reviewer_names, source_branch, dest_branch
-		FROM pr_commits WHERE repo_slug = $1 AND pr_id = $2
-	`, repoSlug, prID)
+	row := s.pool.QueryRow(ctx,
+		`SELECT `+prCommitColumns+` FROM pr_commits c WHERE c.repo_slug = $1 AND c.pr_id = $2`,
+		repoSlug, prID,
+	)
 	var rec PRCommitRecord
-	var reviewersJSON string
-	if err := row.Scan(&rec.RepoSlug, &rec.PRID, &rec.CommitHash, &rec.Title, &rec.URL,
-		&rec.AuthorName, &reviewersJSON, &rec.SourceBranch, &rec.DestBranch); err != nil {
+	if err := scanPRCommit(row, &rec); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	json.Unmarshal([]byte(reviewersJSON), &rec.ReviewerNames)
 	return &rec, nil
 }
 
+// TouchPRCommit records the state Bitbucket reported for a PR in any webhook event, and
+// moves its last-activity time forward to updatedAt. Unknown PRs are ignored.
+func (s *RepoStore) TouchPRCommit(ctx context.Context, repoSlug string, prID int, state string, updatedAt time.Time) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE pr_commits SET state = $3, updated_at = GREATEST(updated_at, $4)
+		WHERE repo_slug = $1 AND pr_id = $2
+	`, repoSlug, prID, state, updatedAt)
+	return err
+}
+
 // GetPRsByCommit returns all PR IDs whose source commit matches the given hash.
 func (s *RepoStore) GetPRsByCommit(ctx context.Context, repoSlug, commitHash string) ([]int, error) {
 	rows, err := s.pool.Query(ctx,
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SlackInstallation holds the bot credentials issued when the app is installed into a Slack team.
+type SlackInstallation struct {
+	TeamID    string
+	TeamName  string
+	BotUserID string
+	BotToken  string
+}
+
+// SaveSlackInstallation stores or replaces the bot token for a Slack team, sealed
+// under the active key when a keyring is configured.
+func (s *RepoStore) SaveSlackInstallation(ctx context.Context, inst SlackInstallation) error {
+	keyID, dataKey, sealed, err := s.sealTokens(inst.BotToken)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO slack_installations (team_id, team_name, bot_user_id, bot_token, key_id, data_key, installed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (team_id) DO UPDATE SET
+			team_name    = EXCLUDED.team_name,
+			bot_user_id  = EXCLUDED.bot_user_id,
+			bot_token    = EXCLUDED.bot_token,
+			key_id       = EXCLUDED.key_id,
+			data_key     = EXCLUDED.data_key,
+			installed_at = NOW()
+	`, inst.TeamID, inst.TeamName, inst.BotUserID, sealed[0], keyID, dataKey)
+	return err
+}
+
+// GetSlackInstallation returns the installation for a Slack team, or nil if the app
+// was never installed there through the OAuth flow.
+func (s *RepoStore) GetSlackInstallation(ctx context.Context, teamID string) (*SlackInstallation, error) {
+	row := s.pool.QueryRow(ctx,
+		`SELECT team_id, team_name, bot_user_id, bot_token, key_id, data_key FROM slack_installations WHERE team_id = $1`,
+		teamID,
+	)
+	var inst SlackInstallation
+	var keyID, dataKey string
+	if err := row.Scan(&inst.TeamID, &inst.TeamName, &inst.BotUserID, &inst.BotToken, &keyID, &dataKey); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	opened, err := s.openTokens(keyID, dataKey, inst.BotToken)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt bot token for team %s: %w", teamID, err)
+	}
+	inst.BotToken = opened[0]
+	return &inst, nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"slices"
+	"strings"
+
+	"bitbucket-slack-bot/internal/glob"
+)
+
+// Notification kinds a subscription can filter on.
+const (
+	EventCreated          = "created"
+	EventUpdated          = "updated"
+	EventMerged           = "merged"
+	EventDeclined         = "declined"
+	EventApproved         = "approved"
+	EventUnapproved       = "unapproved"
+	EventChangesRequested = "changes_requested"
+	EventComment          = "comment"
+	EventBuildStarted     = "build_started"
+	EventBuildPassed      = "build_passed"
+	EventBuildFailed      = "build_failed"
+	EventBuildStopped     = "build_stopped"
+)
+
+// FilterEvents lists every notification kind accepted by SubscriptionFilter.Events.
+var FilterEvents = []string{
+	EventCreated, EventUpdated, EventMerged, EventDeclined,
+	EventApproved, EventUnapproved, EventChangesRequested, EventComment,
+	EventBuildStarted, EventBuildPassed, EventBuildFailed, EventBuildStopped,
+}
+
+// SubscriptionFilter narrows which notifications a subscription receives.
+// Empty fields place no restriction.
+type SubscriptionFilter struct {
+	Events         []string // notification kinds to deliver
+	TargetBranches []string // glob patterns for PR destination branches to deliver for
+	SourceBranches []string // glob patterns for PR source branches to deliver for
+	ExcludeAuthors []string // PR authors (display names, case-insensitive) to ignore
+	Paths          []string // glob patterns; the PR must change at least one matching file
+}
+
+// FilterEvent is one notification as seen by SubscriptionFilter.
+type FilterEvent struct {
+	Kind         string
+	TargetBranch string
+	SourceBranch string
+	Author       string
+	Paths        []string // files the PR changes; nil when unknown
+}
+
+// Allows reports whether ev passes the filter. When the PR's changed files are
+// unknown the path filter is not applied, so a failed diffstat lookup never
+// silently drops notifications.
+func (f SubscriptionFilter) Allows(ev FilterEvent) bool {
+	if len(f.Events) > 0 && !slices.Contains(f.Events, ev.Kind) {
+		return false
+	}
+	return f.MatchesPR(ev)
+}
+
+// MatchesPR is Allows without the event kind check: it reports whether the PR
+// described by ev falls within the filter's branches, authors and paths.
+func (f SubscriptionFilter) MatchesPR(ev FilterEvent) bool {
+	if len(f.TargetBranches) > 0 && !glob.MatchAny(f.TargetBranches, ev.TargetBranch) {
+		return false
+	}
+	if len(f.SourceBranches) > 0 && !glob.MatchAny(f.SourceBranches, ev.SourceBranch) {
+		return false
+	}
+	for _, a := range f.ExcludeAuthors {
+		if strings.EqualFold(a, ev.Author) {
+			return false
+		}
+	}
+	if len(f.Paths) > 0 && len(ev.Paths) > 0 && !slices.ContainsFunc(ev.Paths, func(p string) bool {
+		return glob.MatchAny(f.Paths, p)
+	}) {
+		return false
+	}
+	return true
+}
+
+// IsZero reports whether the filter places no restriction at all.
+func (f SubscriptionFilter) IsZero() bool {
+	return len(f.Events) == 0 && len(f.TargetBranches) == 0 && len(f.SourceBranches) == 0 &&
+		len(f.ExcludeAuthors) == 0 && len(f.Paths) == 0
+}
+
+// String describes the filter for display, e.g. "events: merged · target: main".
+func (f SubscriptionFilter) String() string {
+	var parts []string
+	if len(f.Events) > 0 {
+		parts = append(parts, "events: "+strings.Join(f.Events, ", "))
+	}
+	if len(f.TargetBranches) > 0 {
+		parts = append(parts, "target: "+strings.Join(f.TargetBranches, ", "))
+	}
+	if len(f.SourceBranches) > 0 {
+		parts = append(parts, "source: "+strings.Join(f.SourceBranches, ", "))
+	}
+	if len(f.Paths) > 0 {
+		parts = append(parts, "paths: "+strings.Join(f.Paths, ", "))
+	}
+	if len(f.ExcludeAuthors) > 0 {
+		parts = append(parts, "excluding: "+strings.Join(f.ExcludeAuthors, ", "))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// orEmpty returns s, or an empty non-nil slice so TEXT[] NOT NULL columns accept it.
+func orEmpty(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
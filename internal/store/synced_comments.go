@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// FindPRByMessage returns the PR whose card is the message ts in channelID,
+// or "" if the message is not a PR card.
+func (s *RepoStore) FindPRByMessage(ctx context.Context, channelID, messageTS string) (repoSlug string, prID int, err error) {
+	err = s.pool.QueryRow(ctx,
+		`SELECT repo_slug, pr_id FROM pr_messages WHERE channel_id = $1 AND message_ts = $2`,
+		channelID, messageTS,
+	).Scan(&repoSlug, &prID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", 0, nil
+	}
+	return repoSlug, prID, err
+}
+
+// ClaimSyncedComment records that the Slack message ts in channelID is being posted
+// as a comment with the given Markdown body on the PR. Returns false if it was
+// already claimed.
+func (s *RepoStore) ClaimSyncedComment(ctx context.Context, channelID, messageTS, repoSlug string, prID int, body string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO synced_comments (channel_id, message_ts, repo_slug, pr_id, body)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (channel_id, message_ts) DO NOTHING
+	`, channelID, messageTS, repoSlug, prID, body)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// SetSyncedCommentID stores the Bitbucket comment created for a claimed Slack message,
+// unless SyncedCommentChannel has already matched the comment to a claim.
+func (s *RepoStore) SetSyncedCommentID(ctx context.Context, channelID, messageTS string, commentID int) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE synced_comments c SET comment_id = $3
+		WHERE c.channel_id = $1 AND c.message_ts = $2
+		  AND NOT EXISTS (SELECT 1 FROM synced_comments o WHERE o.repo_slug = c.repo_slug AND o.comment_id = $3)
+	`, channelID, messageTS, commentID)
+	return err
+}
+
+// DeleteSyncedComment releases a claim whose comment could not be posted.
+func (s *RepoStore) DeleteSyncedComment(ctx context.Context, channelID, messageTS string) error {
+	_, err := s.pool.Exec(ctx,
+		`DELETE FROM synced_comments WHERE channel_id = $1 AND message_ts = $2`,
+		channelID, messageTS,
+	)
+	return err
+}
+
+// SyncedCommentChannel returns the channel a PR comment was posted from via Slack,
+// or "" if the comment was made in Bitbucket. The comment's webhook may arrive before
+// its ID has been saved with SetSyncedCommentID; the oldest claim on the PR still
+// waiting for an ID with the same body is then taken to be it, and given commentID.
+func (s *RepoStore) SyncedCommentChannel(ctx context.Context, repoSlug string, prID, commentID int, body string) (string, error) {
+	var channelID string
+	err := s.pool.QueryRow(ctx, `
+		WITH pending AS (
+			UPDATE synced_comments SET comment_id = $3
+			WHERE (channel_id, message_ts) = (
+				SELECT channel_id, message_ts FROM synced_comments
+				WHERE repo_slug = $1 AND pr_id = $2 AND comment_id IS NULL AND body = $4
+				ORDER BY created_at
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+			AND NOT EXISTS (SELECT 1 FROM synced_comments WHERE repo_slug = $1 AND comment_id = $3)
+			RETURNING channel_id
+		)
+		SELECT channel_id FROM synced_comments WHERE repo_slug = $1 AND comment_id = $3
+		UNION ALL
+		SELECT channel_id FROM pending
+		LIMIT 1
+	`, repoSlug, prID, commentID, body).Scan(&channelID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return channelID, err
+}
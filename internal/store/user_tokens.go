@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UserTokenRecord holds a Slack user's own Bitbucket OAuth tokens, linked in TeamID.
+type UserTokenRecord struct {
+	TeamID       string
+	SlackUserID  string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// SaveUserToken stores or updates a user's OAuth tokens in teamID, encrypting them if a keyring is configured.
+func (s *RepoStore) SaveUserToken(ctx context.Context, teamID, slackUserID, accessToken, refreshToken string, expiresAt time.Time) error {
+	keyID, dataKey, sealed, err := s.sealTokens(accessToken, refreshToken)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO user_tokens (team_id, slack_user_id, access_token, refresh_token, expires_at, key_id, data_key, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (team_id, slack_user_id) DO UPDATE SET
+			access_token  = EXCLUDED.access_token,
+			refresh_token = EXCLUDED.refresh_token,
+			expires_at    = EXCLUDED.expires_at,
+			key_id        = EXCLUDED.key_id,
+			data_key      = EXCLUDED.data_key,
+			updated_at    = NOW()
+	`, teamID, slackUserID, sealed[0], sealed[1], expiresAt, keyID, dataKey)
+	return err
+}
+
+// GetUserToken retrieves a user's OAuth tokens in teamID. Returns nil if the user has none there.
+func (s *RepoStore) GetUserToken(ctx context.Context, teamID, slackUserID string) (*UserTokenRecord, error) {
+	var t UserTokenRecord
+	var keyID, dataKey string
+	err := s.pool.QueryRow(ctx, `
+		SELECT team_id, slack_user_id, access_token, refresh_token, expires_at, key_id, data_key
+		FROM user_tokens WHERE team_id = $1 AND slack_user_id = $2
+	`, teamID, slackUserID).Scan(&t.TeamID, &t.SlackUserID, &t.AccessToken, &t.RefreshToken, &t.ExpiresAt, &keyID, &dataKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	opened, err := s.openTokens(keyID, dataKey, t.AccessToken, t.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt token for user %s: %w", t.SlackUserID, err)
+	}
+	t.AccessToken, t.RefreshToken = opened[0], opened[1]
+	return &t, nil
+}
+
+// DeleteUserToken removes a user's OAuth tokens in teamID, e.g. after Bitbucket revoked them.
+func (s *RepoStore) DeleteUserToken(ctx context.Context, teamID, slackUserID string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM user_tokens WHERE team_id = $1 AND slack_user_id = $2`, teamID, slackUserID)
+	return err
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// RecordWebhookDelivery remembers a webhook delivery by key (its X-Request-UUID, or a
+// digest of its content) and reports whether it is new.
+func (s *RepoStore) RecordWebhookDelivery(ctx context.Context, key, eventKey, hookUUID string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO webhook_deliveries (request_uuid, event_key, hook_uuid)
+		VALUES ($1, $2, $3) ON CONFLICT DO NOTHING
+	`, key, eventKey, hookUUID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ForgetWebhookDeliveries removes recorded deliveries, so a redelivery under the same
+// keys is processed again.
+func (s *RepoStore) ForgetWebhookDeliveries(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := s.pool.Exec(ctx, `DELETE FROM webhook_deliveries WHERE request_uuid = ANY($1)`, keys)
+	return err
+}
+
+// PurgeWebhookDeliveries forgets deliveries received before cutoff, so the table stays
+// small, and returns how many were removed.
+func (s *RepoStore) PurgeWebhookDeliveries(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM webhook_deliveries WHERE received_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}